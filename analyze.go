@@ -0,0 +1,108 @@
+package renderfs
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// AnalyzeSource walks the source filesystem and reports, for every
+// source-relative path that references template variables in its name or
+// content, the sorted variable paths it needs. Ignore patterns are honoured in
+// the same way as Copy. Nothing is rendered, so the result describes the
+// inputs a template tree expects.
+func AnalyzeSource(source fs.FS, opts Options) (map[string][]string, error) {
+	if source == nil {
+		return nil, fmt.Errorf("renderfs: source filesystem is required")
+	}
+
+	matcher, err := buildIgnoreMatcher(source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := analysisSkip(source, opts)
+	result := make(map[string][]string)
+	err = fs.WalkDir(source, ".", func(rel string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if rel == "." {
+			return nil
+		}
+		if isIgnored(matcher, rel, d.IsDir(), opts.IncludeIgnoreFile) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		vars := extractVariables(rel, skip)
+		if d.Type().IsRegular() {
+			content, err := fs.ReadFile(source, rel)
+			if err != nil {
+				return fmt.Errorf("renderfs: read %s: %w", rel, err)
+			}
+			vars = append(vars, contentVariables(content, opts, skip)...)
+		}
+
+		if vars = dedupeSorted(vars); len(vars) > 0 {
+			result[rel] = vars
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// analysisSkip extends the identifiers skipped during extraction with the
+// builtins every template receives, so helpers such as now are not reported
+// as context the caller must provide.
+func analysisSkip(source fs.FS, opts Options) map[string]struct{} {
+	base := skipIdentifiers(opts)
+	builtins := withBuiltins(nil, source, opts)
+	skip := make(map[string]struct{}, len(base)+len(builtins))
+	for name := range base {
+		skip[name] = struct{}{}
+	}
+	for name := range builtins {
+		skip[name] = struct{}{}
+	}
+	return skip
+}
+
+// contentVariables extracts the variables a file body needs, discounting
+// values its own front-matter provides when FrontMatter is enabled.
+func contentVariables(content []byte, opts Options, skip map[string]struct{}) []string {
+	var provided map[string]interface{}
+	if opts.FrontMatter {
+		if values, body, ok, err := splitFrontMatter(content); err == nil && ok {
+			provided, content = values, body
+		}
+	}
+
+	var vars []string
+	for _, candidate := range collectVariableCandidates(string(content)) {
+		if _, skipped := skip[candidate.base]; skipped {
+			continue
+		}
+		if _, ok := provided[candidate.base]; ok {
+			continue
+		}
+		vars = append(vars, candidate.path)
+	}
+	return vars
+}
+
+func dedupeSorted(values []string) []string {
+	sort.Strings(values)
+	out := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
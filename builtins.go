@@ -0,0 +1,118 @@
+package renderfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// withBuiltins returns ctx extended with the functions renderfs provides to
+// every template. Entries already present in ctx take precedence, so a
+// context can shadow a builtin.
+func withBuiltins(ctx pongo2.Context, source fs.FS, opts Options) pongo2.Context {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	random := newRandomHelpers(opts.RandSeed)
+	builtins := map[string]interface{}{
+		"now":     now,
+		"hash":    sourceHash(source),
+		"uuid":    random.uuid,
+		"randint": random.randint,
+		"choice":  random.choice,
+		"relpath": relativePath(""),
+	}
+	if len(opts.EnvVars) > 0 {
+		builtins["env"] = environment(opts.EnvVars)
+	}
+	if opts.Translations != nil {
+		builtins["t"] = translate(opts.Translations, opts.Locale, opts.FallbackLocale)
+	}
+
+	merged := make(pongo2.Context, len(ctx)+len(builtins))
+	for k, v := range builtins {
+		merged[k] = v
+	}
+	for k, v := range ctx {
+		merged[k] = v
+	}
+	return merged
+}
+
+// sourceHash returns the hash template function: hash('assets/app.css')
+// yields the hex sha256 of the raw bytes of that file as stored in the
+// source, before any rendering. Paths are relative to the source root.
+func sourceHash(source fs.FS) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		clean := path.Clean(name)
+		if !fs.ValidPath(clean) || clean == "." {
+			return "", fmt.Errorf("renderfs: hash: invalid source path %q", name)
+		}
+		content, err := fs.ReadFile(source, clean)
+		if err != nil {
+			return "", fmt.Errorf("renderfs: hash %s: %w", clean, err)
+		}
+		return checksum(content), nil
+	}
+}
+
+// relativePath returns the relpath template function for the file at
+// current. relpath(from, to) gives the path of to relative to the directory
+// containing from, in the manner of filepath.Rel; both are
+// destination-relative, slash-separated paths. relpath(to) measures from the
+// file being rendered.
+func relativePath(current string) func(args ...string) (string, error) {
+	return func(args ...string) (string, error) {
+		var from, to string
+		switch len(args) {
+		case 1:
+			if current == "" {
+				return "", fmt.Errorf("renderfs: relpath: no current file, pass both from and to")
+			}
+			from, to = current, args[0]
+		case 2:
+			from, to = args[0], args[1]
+		default:
+			return "", fmt.Errorf("renderfs: relpath takes 1 or 2 arguments, got %d", len(args))
+		}
+
+		rel, err := filepath.Rel(filepath.FromSlash(path.Dir(path.Clean(from))), filepath.FromSlash(path.Clean(to)))
+		if err != nil {
+			return "", fmt.Errorf("renderfs: relpath: %w", err)
+		}
+		return filepath.ToSlash(rel), nil
+	}
+}
+
+// environment returns the allowlisted environment variables that are set.
+func environment(names []string) map[string]interface{} {
+	env := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// translate returns the t template function, which looks key up in the
+// translations for locale and then, when set, for fallback.
+func translate(translations map[string]map[string]string, locale, fallback string) func(key string) (string, error) {
+	return func(key string) (string, error) {
+		if value, ok := translations[locale][key]; ok {
+			return value, nil
+		}
+		if fallback != "" {
+			if value, ok := translations[fallback][key]; ok {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("renderfs: t: no %q translation for %q", locale, key)
+	}
+}
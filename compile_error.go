@@ -0,0 +1,121 @@
+package renderfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// snippetRadius is the number of characters kept on either side of the
+// error position in TemplateCompileError.Snippet.
+const snippetRadius = 20
+
+// TemplateCompileError reports a template that pongo2 could not compile,
+// such as one with an unclosed tag or an unknown filter.
+type TemplateCompileError struct {
+	// Path is the source-relative path of the file, path, or included
+	// template that failed, when known.
+	Path string
+
+	// Line and Column give the 1-based position of the error, or zero when
+	// pongo2 did not report one.
+	Line   int
+	Column int
+
+	// Snippet holds the text around the error position on its line, best
+	// effort; it is empty when the position or source is unknown.
+	Snippet string
+
+	// Err is the error returned by pongo2.
+	Err error
+}
+
+func (e *TemplateCompileError) Error() string {
+	msg := e.Err.Error()
+	var perr *pongo2.Error
+	if errors.As(e.Err, &perr) && perr.OrigError != nil {
+		msg = perr.OrigError.Error()
+	}
+
+	var b strings.Builder
+	b.WriteString("renderfs: ")
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		if e.Line > 0 {
+			fmt.Fprintf(&b, ":%d:%d", e.Line, e.Column)
+		}
+		b.WriteString(": ")
+	} else if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d, column %d: ", e.Line, e.Column)
+	}
+	b.WriteString(msg)
+	if e.Snippet != "" {
+		fmt.Fprintf(&b, " near %q", e.Snippet)
+	}
+	return b.String()
+}
+
+func (e *TemplateCompileError) Unwrap() error { return e.Err }
+
+// newCompileError wraps a pongo2 compile error for tpl. Errors raised in a
+// template tpl loads name that template, and its snippet is read from source.
+func newCompileError(source fs.FS, tpl string, err error) error {
+	ce := &TemplateCompileError{Err: err}
+	var perr *pongo2.Error
+	if !errors.As(err, &perr) {
+		return ce
+	}
+	ce.Line, ce.Column = perr.Line, perr.Column
+
+	text := tpl
+	if perr.Filename != "" && perr.Filename != "<string>" {
+		ce.Path = perr.Filename
+		text = ""
+		if source != nil {
+			if content, readErr := fs.ReadFile(source, perr.Filename); readErr == nil {
+				text = string(content)
+			}
+		}
+	}
+	ce.Snippet = snippetAt(text, perr.Line, perr.Column)
+	return ce
+}
+
+// snippetAt returns up to snippetRadius characters on either side of the
+// 1-based line and column in text, trimmed of surrounding space.
+func snippetAt(text string, line, column int) string {
+	if line <= 0 || column <= 0 {
+		return ""
+	}
+	lines := strings.Split(text, "\n")
+	if line > len(lines) {
+		return ""
+	}
+	runes := []rune(lines[line-1])
+	pos := min(column-1, len(runes))
+	start := max(pos-snippetRadius, 0)
+	end := min(pos+snippetRadius, len(runes))
+	return strings.TrimSpace(string(runes[start:end]))
+}
+
+// templateError reports err from rendering or compiling the template at rel,
+// where op describes the step, e.g. "render file". A TemplateCompileError for
+// rel itself already names the file and position, so it is returned without
+// a second prefix, its Line moved down by lineOffset: the number of lines,
+// such as a mode directive or front matter, stripped before the template
+// body. Other errors, including compile errors in templates rel loads, are
+// wrapped with op and rel.
+func templateError(err error, op, rel string, lineOffset int) error {
+	var ce *TemplateCompileError
+	if errors.As(err, &ce) && ce.Path == "" {
+		ce.Path = rel
+		if ce.Line > 0 {
+			ce.Line += lineOffset
+		}
+		return err
+	}
+	return fmt.Errorf("renderfs: %s %s: %w", op, rel, err)
+}
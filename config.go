@@ -0,0 +1,262 @@
+package renderfs
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// Config is a serializable form of Options for tools that load settings from
+// JSON or YAML. Enumerations are spelled as strings, modes as octal strings
+// such as "0755", and durations use time.ParseDuration syntax. Convert it with ToOptions; options that hold
+// functions or streams have no counterpart here and can be set afterwards.
+type Config struct {
+	Context     map[string]interface{} `json:"context,omitempty" yaml:"context,omitempty"`
+	PathContext map[string]interface{} `json:"path_context,omitempty" yaml:"path_context,omitempty"`
+
+	// OnConflict is "overwrite" (the default), "skip", or "fail".
+	OnConflict string `json:"on_conflict,omitempty" yaml:"on_conflict,omitempty"`
+
+	IgnorePatterns         []string          `json:"ignore_patterns,omitempty" yaml:"ignore_patterns,omitempty"`
+	RenderIgnorePatterns   bool              `json:"render_ignore_patterns,omitempty" yaml:"render_ignore_patterns,omitempty"`
+	IncludeIgnoreFile      bool              `json:"include_ignore_file,omitempty" yaml:"include_ignore_file,omitempty"`
+	UseGitignore           bool              `json:"use_gitignore,omitempty" yaml:"use_gitignore,omitempty"`
+	IgnoreRenderedPatterns []string          `json:"ignore_rendered_patterns,omitempty" yaml:"ignore_rendered_patterns,omitempty"`
+	VerbatimPatterns       []string          `json:"verbatim_patterns,omitempty" yaml:"verbatim_patterns,omitempty"`
+	OnceGlobs              []string          `json:"once_globs,omitempty" yaml:"once_globs,omitempty"`
+	AppendGlobs            []string          `json:"append_globs,omitempty" yaml:"append_globs,omitempty"`
+	MaxDepth               int               `json:"max_depth,omitempty" yaml:"max_depth,omitempty"`
+	MaxNameLength          int               `json:"max_name_length,omitempty" yaml:"max_name_length,omitempty"`
+	Flatten                bool              `json:"flatten,omitempty" yaml:"flatten,omitempty"`
+	ValidateStructured     bool              `json:"validate_structured,omitempty" yaml:"validate_structured,omitempty"`
+	Redirects              map[string]string `json:"redirects,omitempty" yaml:"redirects,omitempty"`
+	Rename                 map[string]string `json:"rename,omitempty" yaml:"rename,omitempty"`
+	DirModeOverrides       map[string]string `json:"dir_mode_overrides,omitempty" yaml:"dir_mode_overrides,omitempty"`
+
+	SkipUnchanged        bool `json:"skip_unchanged,omitempty" yaml:"skip_unchanged,omitempty"`
+	SkipEmptyFiles       bool `json:"skip_empty_files,omitempty" yaml:"skip_empty_files,omitempty"`
+	RenderSymlinkTargets bool `json:"render_symlink_targets,omitempty" yaml:"render_symlink_targets,omitempty"`
+	FollowSymlinks       bool `json:"follow_symlinks,omitempty" yaml:"follow_symlinks,omitempty"`
+	FailOnSymlinkLoop    bool `json:"fail_on_symlink_loop,omitempty" yaml:"fail_on_symlink_loop,omitempty"`
+	DirectoryData        bool `json:"directory_data,omitempty" yaml:"directory_data,omitempty"`
+	DirectoryConditions  bool `json:"directory_conditions,omitempty" yaml:"directory_conditions,omitempty"`
+	FrontMatter          bool `json:"front_matter,omitempty" yaml:"front_matter,omitempty"`
+	StrictSuffix         bool `json:"strict_suffix,omitempty" yaml:"strict_suffix,omitempty"`
+	PrecompileTemplates  bool `json:"precompile_templates,omitempty" yaml:"precompile_templates,omitempty"`
+	StrictSubscripts     bool `json:"strict_subscripts,omitempty" yaml:"strict_subscripts,omitempty"`
+	ExposeFileList       bool `json:"expose_file_list,omitempty" yaml:"expose_file_list,omitempty"`
+	ExposeSource         bool `json:"expose_source,omitempty" yaml:"expose_source,omitempty"`
+
+	KnownGlobals  []string `json:"known_globals,omitempty" yaml:"known_globals,omitempty"`
+	ExtraKeywords []string `json:"extra_keywords,omitempty" yaml:"extra_keywords,omitempty"`
+
+	// LineEndings is "preserve" (the default), "lf", or "crlf".
+	LineEndings        string `json:"line_endings,omitempty" yaml:"line_endings,omitempty"`
+	EnsureFinalNewline bool   `json:"ensure_final_newline,omitempty" yaml:"ensure_final_newline,omitempty"`
+
+	MaxRenderedSize       int64 `json:"max_rendered_size,omitempty" yaml:"max_rendered_size,omitempty"`
+	MaxSourceFileSize     int64 `json:"max_source_file_size,omitempty" yaml:"max_source_file_size,omitempty"`
+	FailOnOversizedSource bool  `json:"fail_on_oversized_source,omitempty" yaml:"fail_on_oversized_source,omitempty"`
+
+	// RenderTimeout is a duration such as "2s".
+	RenderTimeout string `json:"render_timeout,omitempty" yaml:"render_timeout,omitempty"`
+
+	RandSeed int64    `json:"rand_seed,omitempty" yaml:"rand_seed,omitempty"`
+	EnvVars  []string `json:"env_vars,omitempty" yaml:"env_vars,omitempty"`
+
+	Translations   map[string]map[string]string `json:"translations,omitempty" yaml:"translations,omitempty"`
+	Locale         string                       `json:"locale,omitempty" yaml:"locale,omitempty"`
+	FallbackLocale string                       `json:"fallback_locale,omitempty" yaml:"fallback_locale,omitempty"`
+
+	KeepMarker     string `json:"keep_marker,omitempty" yaml:"keep_marker,omitempty"`
+	DropKeepMarker bool   `json:"drop_keep_marker,omitempty" yaml:"drop_keep_marker,omitempty"`
+	DryRun         bool   `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+	Transactional  bool   `json:"transactional,omitempty" yaml:"transactional,omitempty"`
+}
+
+// ToOptions converts the configuration into Options, parsing enumerations and
+// durations and normalizing decoded context maps.
+func (c Config) ToOptions() (Options, error) {
+	conflict, err := ParseConflictResolution(c.OnConflict)
+	if err != nil {
+		return Options{}, err
+	}
+	endings, err := parseLineEnding(c.LineEndings)
+	if err != nil {
+		return Options{}, err
+	}
+	var timeout time.Duration
+	if c.RenderTimeout != "" {
+		if timeout, err = time.ParseDuration(c.RenderTimeout); err != nil {
+			return Options{}, fmt.Errorf("renderfs: render_timeout: %w", err)
+		}
+	}
+	dirModes, err := parseModes(c.DirModeOverrides)
+	if err != nil {
+		return Options{}, fmt.Errorf("renderfs: dir_mode_overrides: %w", err)
+	}
+	ctx, err := toContext(c.Context)
+	if err != nil {
+		return Options{}, fmt.Errorf("renderfs: context: %w", err)
+	}
+	pathCtx, err := toContext(c.PathContext)
+	if err != nil {
+		return Options{}, fmt.Errorf("renderfs: path_context: %w", err)
+	}
+
+	return Options{
+		Context:                ctx,
+		PathContext:            pathCtx,
+		OnConflict:             conflict,
+		IgnorePatterns:         c.IgnorePatterns,
+		RenderIgnorePatterns:   c.RenderIgnorePatterns,
+		IncludeIgnoreFile:      c.IncludeIgnoreFile,
+		UseGitignore:           c.UseGitignore,
+		IgnoreRenderedPatterns: c.IgnoreRenderedPatterns,
+		VerbatimPatterns:       c.VerbatimPatterns,
+		OnceGlobs:              c.OnceGlobs,
+		AppendGlobs:            c.AppendGlobs,
+		MaxDepth:               c.MaxDepth,
+		MaxNameLength:          c.MaxNameLength,
+		Flatten:                c.Flatten,
+		ValidateStructured:     c.ValidateStructured,
+		Redirects:              c.Redirects,
+		Rename:                 c.Rename,
+		DirModeOverrides:       dirModes,
+		SkipUnchanged:          c.SkipUnchanged,
+		SkipEmptyFiles:         c.SkipEmptyFiles,
+		RenderSymlinkTargets:   c.RenderSymlinkTargets,
+		FollowSymlinks:         c.FollowSymlinks,
+		FailOnSymlinkLoop:      c.FailOnSymlinkLoop,
+		DirectoryData:          c.DirectoryData,
+		DirectoryConditions:    c.DirectoryConditions,
+		FrontMatter:            c.FrontMatter,
+		StrictSuffix:           c.StrictSuffix,
+		PrecompileTemplates:    c.PrecompileTemplates,
+		StrictSubscripts:       c.StrictSubscripts,
+		ExposeFileList:         c.ExposeFileList,
+		ExposeSource:           c.ExposeSource,
+		KnownGlobals:           c.KnownGlobals,
+		ExtraKeywords:          c.ExtraKeywords,
+		LineEndings:            endings,
+		EnsureFinalNewline:     c.EnsureFinalNewline,
+		MaxRenderedSize:        c.MaxRenderedSize,
+		MaxSourceFileSize:      c.MaxSourceFileSize,
+		FailOnOversizedSource:  c.FailOnOversizedSource,
+		RenderTimeout:          timeout,
+		RandSeed:               c.RandSeed,
+		EnvVars:                c.EnvVars,
+		Translations:           c.Translations,
+		Locale:                 c.Locale,
+		FallbackLocale:         c.FallbackLocale,
+		KeepMarker:             c.KeepMarker,
+		DropKeepMarker:         c.DropKeepMarker,
+		DryRun:                 c.DryRun,
+		Transactional:          c.Transactional,
+	}, nil
+}
+
+// ParseConflictResolution parses "overwrite", "skip", or "fail", ignoring
+// case. An empty string yields Overwrite.
+func ParseConflictResolution(s string) (ConflictResolution, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "overwrite":
+		return Overwrite, nil
+	case "skip":
+		return Skip, nil
+	case "fail":
+		return Fail, nil
+	}
+	return Overwrite, fmt.Errorf("renderfs: unknown conflict resolution %q", s)
+}
+
+func parseLineEnding(s string) (LineEnding, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "preserve":
+		return PreserveLineEndings, nil
+	case "lf":
+		return LF, nil
+	case "crlf":
+		return CRLF, nil
+	}
+	return PreserveLineEndings, fmt.Errorf("renderfs: unknown line endings %q", s)
+}
+
+// parseModes converts a map of octal permission strings such as "0755" into
+// file modes.
+func parseModes(m map[string]string) (map[string]fs.FileMode, error) {
+	if m == nil {
+		return nil, nil
+	}
+	modes := make(map[string]fs.FileMode, len(m))
+	for pattern, s := range m {
+		perm, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+		if err != nil || perm > 0o777 {
+			return nil, fmt.Errorf("invalid mode %q for %s", s, pattern)
+		}
+		modes[pattern] = fs.FileMode(perm)
+	}
+	return modes, nil
+}
+
+// toContext converts a decoded map into a pongo2.Context, turning the
+// map[interface{}]interface{} values some YAML decoders produce into
+// string-keyed maps the renderer can traverse.
+func toContext(m map[string]interface{}) (pongo2.Context, error) {
+	if m == nil {
+		return nil, nil
+	}
+	ctx := make(pongo2.Context, len(m))
+	for key, value := range m {
+		converted, err := normalizeDecoded(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		ctx[key] = converted
+	}
+	return ctx, nil
+}
+
+func normalizeDecoded(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted, err := normalizeDecoded(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			out[key] = converted
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string key %v", key)
+			}
+			converted, err := normalizeDecoded(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			out[name] = converted
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			converted, err := normalizeDecoded(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = converted
+		}
+		return out, nil
+	}
+	return value, nil
+}
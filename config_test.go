@@ -0,0 +1,150 @@
+package renderfs_test
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/your-org/renderfs"
+	"github.com/your-org/renderfs/writers"
+)
+
+func TestParseConflictResolution(t *testing.T) {
+	cases := map[string]renderfs.ConflictResolution{
+		"":          renderfs.Overwrite,
+		"overwrite": renderfs.Overwrite,
+		"skip":      renderfs.Skip,
+		"Fail":      renderfs.Fail,
+	}
+	for input, want := range cases {
+		got, err := renderfs.ParseConflictResolution(input)
+		if err != nil || got != want {
+			t.Errorf("ParseConflictResolution(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := renderfs.ParseConflictResolution("merge"); err == nil {
+		t.Fatalf("expected unknown mode to fail")
+	}
+}
+
+func TestConfigToOptionsFromYAML(t *testing.T) {
+	raw := []byte(`
+context:
+  project: demo
+  db:
+    hosts: [a, b]
+on_conflict: skip
+line_endings: crlf
+render_timeout: 2s
+ignore_patterns: ["*.log"]
+`)
+	var cfg renderfs.Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	opts, err := cfg.ToOptions()
+	if err != nil {
+		t.Fatalf("ToOptions: %v", err)
+	}
+	if opts.OnConflict != renderfs.Skip || opts.LineEndings != renderfs.CRLF || opts.RenderTimeout != 2*time.Second {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+
+	source := fstest.MapFS{
+		"{{ project }}.txt": {Data: []byte("{{ db.hosts[1] }}")},
+		"debug.log":         {Data: []byte("noise")},
+	}
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["demo.txt"]); got != "b" {
+		t.Fatalf("unexpected output: %v", writer.Contents())
+	}
+	if _, ok := writer.Contents()["debug.log"]; ok {
+		t.Fatalf("expected ignore patterns to carry over")
+	}
+}
+
+func TestConfigToOptionsMapsFields(t *testing.T) {
+	raw := []byte(`
+max_name_length: -1
+dir_mode_overrides:
+  bin: "0700"
+expose_source: true
+expose_file_list: true
+transactional: true
+dry_run: true
+`)
+	var cfg renderfs.Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	opts, err := cfg.ToOptions()
+	if err != nil {
+		t.Fatalf("ToOptions: %v", err)
+	}
+	if opts.DirModeOverrides["bin"] != 0o700 {
+		t.Fatalf("unexpected dir mode overrides: %v", opts.DirModeOverrides)
+	}
+	if opts.MaxNameLength != -1 || !opts.ExposeSource || !opts.ExposeFileList || !opts.Transactional || !opts.DryRun {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+}
+
+func TestConfigToOptionsRejectsInvalidValues(t *testing.T) {
+	for _, raw := range []string{
+		`{"on_conflict": "sometimes"}`,
+		`{"line_endings": "cr"}`,
+		`{"render_timeout": "soon"}`,
+		`{"dir_mode_overrides": {"bin": "0799"}}`,
+	} {
+		var cfg renderfs.Config
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			t.Fatalf("unmarshal %s: %v", raw, err)
+		}
+		if _, err := cfg.ToOptions(); err == nil {
+			t.Errorf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestConflictResolutionText(t *testing.T) {
+	for _, mode := range []renderfs.ConflictResolution{renderfs.Overwrite, renderfs.Skip, renderfs.Fail} {
+		text, err := mode.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText(%d): %v", int(mode), err)
+		}
+		if string(text) != mode.String() {
+			t.Fatalf("MarshalText = %q, String = %q", text, mode.String())
+		}
+		var back renderfs.ConflictResolution
+		if err := back.UnmarshalText(text); err != nil || back != mode {
+			t.Fatalf("round trip of %q = %v, %v", text, back, err)
+		}
+	}
+
+	var settings struct {
+		OnConflict renderfs.ConflictResolution `json:"on_conflict"`
+	}
+	if err := json.Unmarshal([]byte(`{"on_conflict": "fail"}`), &settings); err != nil || settings.OnConflict != renderfs.Fail {
+		t.Fatalf("json decode = %v, %v", settings.OnConflict, err)
+	}
+	encoded, err := json.Marshal(settings)
+	if err != nil || string(encoded) != `{"on_conflict":"fail"}` {
+		t.Fatalf("json encode = %s, %v", encoded, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"on_conflict": "merge"}`), &settings); err == nil {
+		t.Fatalf("expected unknown value to be rejected")
+	}
+	if _, err := renderfs.ConflictResolution(7).MarshalText(); err == nil {
+		t.Fatalf("expected out-of-range value to fail to marshal")
+	}
+	if got := renderfs.ConflictResolution(7).String(); got != "ConflictResolution(7)" {
+		t.Fatalf("String() = %q", got)
+	}
+}
@@ -0,0 +1,203 @@
+package renderfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// MergeContexts deep-merges src over dst and returns the result. Values that
+// are maps (pongo2.Context or map[string]interface{}) on both sides are merged
+// recursively; in every other case, including slices and mismatched types,
+// the value from src wins. Neither input is modified, although maps and other
+// values that are not merged are shared with the inputs.
+func MergeContexts(dst, src pongo2.Context) pongo2.Context {
+	return layerContexts(src, dst)
+}
+
+// SetContextPath assigns value at path within ctx, creating intermediate maps
+// as needed, so SetContextPath(ctx, "params.db.host", "localhost") works on an
+// empty context. Paths use the template syntax: dotted names and quoted
+// subscripts such as servers["eu-west"].port. An existing value along the
+// way that is not a map is replaced by one. ctx is modified in place, along
+// with any nested maps it shares with other contexts.
+func SetContextPath(ctx pongo2.Context, path string, value interface{}) error {
+	if ctx == nil {
+		return fmt.Errorf("renderfs: set %q: context is nil", path)
+	}
+	segments, err := parsePath(path)
+	if err != nil || len(segments) == 0 {
+		return fmt.Errorf("renderfs: set %q: invalid path", path)
+	}
+
+	var keys []string
+	for _, segment := range segments {
+		if segment.name == "" && (len(keys) == 0 || len(segment.subscripts) == 0) {
+			return fmt.Errorf("renderfs: set %q: invalid path", path)
+		}
+		if segment.name != "" {
+			keys = append(keys, segment.name)
+		}
+		for _, sub := range segment.subscripts {
+			if sub.kind != indexKindString {
+				return fmt.Errorf("renderfs: set %q: only quoted subscripts are supported", path)
+			}
+			keys = append(keys, sub.stringValue)
+		}
+	}
+
+	current := map[string]interface{}(ctx)
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := asStringMap(current[key])
+		if !ok {
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		current = next
+	}
+	current[keys[len(keys)-1]] = value
+	return nil
+}
+
+// ApplyOverrides applies CLI-style "path=value" assignments to ctx with
+// SetContextPath, in order, so later overrides win. The path is everything
+// before the first "=". Values are interpreted as follows:
+//
+//   - true and false become bools;
+//   - base-10 integers such as 8080 or -1 become ints;
+//   - text in double quotes is unquoted with Go syntax, and text in single
+//     quotes is taken literally, both yielding strings, so "1.0" stays a
+//     string and quoted values may contain "=" or ".";
+//   - anything else, including an empty value, is kept as a string.
+func ApplyOverrides(ctx pongo2.Context, overrides []string) error {
+	for _, override := range overrides {
+		key, raw, ok := strings.Cut(override, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("renderfs: override %q is not of the form path=value", override)
+		}
+		value, err := parseOverrideValue(raw)
+		if err != nil {
+			return fmt.Errorf("renderfs: override %q: %w", override, err)
+		}
+		if err := SetContextPath(ctx, strings.TrimSpace(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseOverrideValue(raw string) (interface{}, error) {
+	switch {
+	case raw == "true":
+		return true, nil
+	case raw == "false":
+		return false, nil
+	case len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"':
+		return strconv.Unquote(raw)
+	case len(raw) >= 2 && raw[0] == '\'' && raw[len(raw)-1] == '\'':
+		return raw[1 : len(raw)-1], nil
+	}
+	if n, err := strconv.Atoi(raw); err == nil {
+		return n, nil
+	}
+	return raw, nil
+}
+
+// layerContexts combines contexts so that earlier layers take precedence over
+// later ones. Nested maps present in more than one layer are merged into new
+// maps; every other value, including maps found in a single layer, is shared
+// by reference. None of the inputs are modified.
+func layerContexts(layers ...pongo2.Context) pongo2.Context {
+	out := pongo2.Context{}
+	for i := len(layers) - 1; i >= 0; i-- {
+		overlay(out, layers[i])
+	}
+	return out
+}
+
+// overlay writes src over dst, merging values that are maps on both sides.
+func overlay(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if existing, ok := asStringMap(dst[key]); ok {
+			if incoming, ok := asStringMap(value); ok {
+				merged := make(map[string]interface{}, len(existing)+len(incoming))
+				for k, v := range existing {
+					merged[k] = v
+				}
+				overlay(merged, incoming)
+				dst[key] = merged
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+func asStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case pongo2.Context:
+		return m, m != nil
+	case map[string]interface{}:
+		return m, m != nil
+	}
+	return nil, false
+}
+
+// lazyValue is the type of context values computed on first use.
+type lazyValue = func() (interface{}, error)
+
+// memoizeLazy returns ctx with every lazy value, at any depth of nested maps,
+// replaced by a wrapper that calls it at most once. Maps are copied only when
+// they contain lazy values, so the caller's context is never modified.
+func memoizeLazy(ctx pongo2.Context) pongo2.Context {
+	if out, changed := memoizeMap(ctx); changed {
+		return out
+	}
+	return ctx
+}
+
+func memoizeMap(values map[string]interface{}) (map[string]interface{}, bool) {
+	var out map[string]interface{}
+	for key, value := range values {
+		replacement, changed := memoizeValue(value)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]interface{}, len(values))
+			for k, v := range values {
+				out[k] = v
+			}
+		}
+		out[key] = replacement
+	}
+	return out, out != nil
+}
+
+func memoizeValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case lazyValue:
+		if v == nil {
+			return nil, false
+		}
+		var (
+			once   sync.Once
+			result interface{}
+			err    error
+		)
+		return lazyValue(func() (interface{}, error) {
+			once.Do(func() { result, err = v() })
+			return result, err
+		}), true
+	case pongo2.Context:
+		if out, changed := memoizeMap(v); changed {
+			return pongo2.Context(out), true
+		}
+	case map[string]interface{}:
+		return memoizeMap(v)
+	}
+	return value, false
+}
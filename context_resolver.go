@@ -2,28 +2,40 @@ package renderfs
 
 import (
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/flosch/pongo2/v6"
 )
 
-func resolvePath(ctx pongo2.Context, path string) bool {
+// resolvePath reports whether path can be resolved against ctx. A subscript
+// naming an integer context value, as in items[idx], is bounds-checked against
+// slices, arrays, and strings. Other dynamic subscripts are assumed to be
+// available unless strict is set, in which case they are looked up in ctx
+// when they name a context value, and otherwise the value being indexed must
+// at least be a container.
+func resolvePath(ctx pongo2.Context, path string, strict bool) bool {
+	_, ok := lookupPath(ctx, path, strict)
+	return ok
+}
+
+func lookupPath(ctx pongo2.Context, path string, strict bool) (interface{}, bool) {
 	segments, err := parsePath(path)
 	if err != nil {
-		return false
+		return nil, false
 	}
 
 	var current interface{} = ctx
 	for _, segment := range segments {
-		next, ok := lookupSegment(current, segment)
+		next, ok := lookupSegment(ctx, current, segment, strict)
 		if !ok {
-			return false
+			return nil, false
 		}
 		current = next
 	}
 
-	return true
+	return current, true
 }
 
 type pathSegment struct {
@@ -43,6 +55,7 @@ type pathIndex struct {
 	kind        indexKind
 	intValue    int
 	stringValue string
+	expr        string
 }
 
 func parsePath(path string) ([]pathSegment, error) {
@@ -58,10 +71,7 @@ func parsePath(path string) ([]pathSegment, error) {
 
 		for len(expr) > 0 {
 			switch expr[0] {
-			case '.':
-				expr = strings.TrimLeft(expr[1:], " ")
-				goto segmentReady
-			case '[':
+			case '.', '[':
 				goto segmentReady
 			default:
 				nameBuilder.WriteByte(expr[0])
@@ -144,26 +154,70 @@ func parseIndex(content string) pathIndex {
 		}
 	}
 
-	return pathIndex{kind: indexKindUnknown}
+	return pathIndex{kind: indexKindUnknown, expr: content}
 }
 
-func lookupSegment(current interface{}, segment pathSegment) (interface{}, bool) {
+func lookupSegment(ctx pongo2.Context, current interface{}, segment pathSegment, strict bool) (interface{}, bool) {
 	value, ok := getAttribute(current, segment.name)
 	if !ok {
 		return nil, false
 	}
+	if value, ok = evaluateLazy(value); !ok {
+		return nil, false
+	}
+	value = normalizeValue(value)
 
 	for _, sub := range segment.subscripts {
+		if sub.kind == indexKindUnknown {
+			// Integer index variables are always checked against sequences;
+			// strict mode applies whatever the variable resolves to.
+			evaluated := evaluateIndex(ctx, sub)
+			if strict || (evaluated.kind == indexKindInt && isSequence(value)) {
+				sub = evaluated
+			}
+			if strict && sub.kind == indexKindUnknown {
+				if !isIndexable(value) {
+					return nil, false
+				}
+				continue
+			}
+		}
 		next, ok := applySubscript(value, sub)
 		if !ok {
 			return nil, false
 		}
-		value = next
+		value = normalizeValue(next)
 	}
 
 	return value, true
 }
 
+// evaluateLazy calls a lazy context value, as pongo2 would when rendering it.
+// A value whose function fails is treated as unresolvable.
+func evaluateLazy(value interface{}) (interface{}, bool) {
+	fn, ok := value.(lazyValue)
+	if !ok || fn == nil {
+		return value, true
+	}
+	result, err := fn()
+	if err != nil {
+		return nil, false
+	}
+	return result, true
+}
+
+// normalizeValue unwraps *pongo2.Value so the next lookup sees the underlying
+// value. Pointers are kept intact so their method sets remain reachable.
+func normalizeValue(value interface{}) interface{} {
+	for {
+		pv, ok := value.(*pongo2.Value)
+		if !ok || pv == nil {
+			return value
+		}
+		value = pv.Interface()
+	}
+}
+
 func getAttribute(current interface{}, name string) (interface{}, bool) {
 	if name == "" {
 		return current, true
@@ -184,38 +238,59 @@ func getAttribute(current interface{}, name string) (interface{}, bool) {
 		return nil, false
 	}
 
+	names := []string{name, toExportedName(name)}
 	switch rv.Kind() {
 	case reflect.Map:
-		key, allowed := buildMapKey(name, rv.Type().Key())
-		if !allowed {
-			return nil, false
-		}
-		val := rv.MapIndex(key)
-		if !val.IsValid() {
-			return nil, false
+		if key, allowed := buildMapKey(name, rv.Type().Key()); allowed {
+			if val := rv.MapIndex(key); val.IsValid() {
+				return val.Interface(), true
+			}
 		}
-		return val.Interface(), true
 	case reflect.Struct:
-		field := rv.FieldByName(name)
-		if !field.IsValid() && name != "" {
-			field = rv.FieldByName(toExportedName(name))
-		}
-		if field.IsValid() && field.CanInterface() {
-			return field.Interface(), true
+		for _, candidate := range names {
+			if val, ok := structField(rv, candidate); ok {
+				return val, true
+			}
 		}
+	}
 
-		if method := rv.MethodByName(name); method.IsValid() {
+	// Any named type can carry methods, not just structs.
+	for _, candidate := range names {
+		if method, ok := methodByName(rv, candidate); ok {
 			return callNoArgMethod(method)
 		}
+	}
+	return nil, false
+}
 
-		if name != "" {
-			if method := rv.MethodByName(toExportedName(name)); method.IsValid() {
-				return callNoArgMethod(method)
-			}
-		}
+// structField resolves a (possibly promoted) field without panicking when an
+// embedded pointer along the way is nil.
+func structField(rv reflect.Value, name string) (interface{}, bool) {
+	sf, ok := rv.Type().FieldByName(name)
+	if !ok {
+		return nil, false
 	}
+	field, err := rv.FieldByIndexErr(sf.Index)
+	if err != nil || !field.CanInterface() {
+		return nil, false
+	}
+	return field.Interface(), true
+}
 
-	return nil, false
+// methodByName looks the method up on the value and, when the value was
+// reached through a pointer, on the pointer as well. pongo2 resolves methods
+// before dereferencing, so pointer receivers (including those promoted from
+// embedded structs) are callable at render time.
+func methodByName(rv reflect.Value, name string) (reflect.Value, bool) {
+	if method := rv.MethodByName(name); method.IsValid() {
+		return method, true
+	}
+	if rv.CanAddr() {
+		if method := rv.Addr().MethodByName(name); method.IsValid() {
+			return method, true
+		}
+	}
+	return reflect.Value{}, false
 }
 
 func toReflectValue(value interface{}) (reflect.Value, bool) {
@@ -269,6 +344,60 @@ func callNoArgMethod(method reflect.Value) (interface{}, bool) {
 	}
 }
 
+// evaluateIndex resolves a dynamic subscript that names a context value, such
+// as the i in items[i]. Anything else is returned unchanged.
+func evaluateIndex(ctx pongo2.Context, sub pathIndex) pathIndex {
+	if sub.expr == "" || !identifierPathRegex.MatchString(sub.expr) {
+		return sub
+	}
+	value, ok := lookupPath(ctx, sub.expr, false)
+	if !ok {
+		return sub
+	}
+
+	rv, ok := toReflectValue(value)
+	if !ok {
+		return sub
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return pathIndex{kind: indexKindInt, intValue: int(rv.Int())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return pathIndex{kind: indexKindInt, intValue: int(rv.Uint())}
+	case reflect.String:
+		return pathIndex{kind: indexKindString, stringValue: rv.String()}
+	}
+	return sub
+}
+
+var identifierPathRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// isSequence reports whether value is indexed by position.
+func isSequence(value interface{}) bool {
+	rv, ok := toReflectValue(value)
+	if !ok {
+		return false
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Slice, reflect.String:
+		return true
+	}
+	return false
+}
+
+// isIndexable reports whether value is something a subscript can apply to.
+func isIndexable(value interface{}) bool {
+	rv, ok := toReflectValue(value)
+	if !ok {
+		return false
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Slice, reflect.String, reflect.Map:
+		return true
+	}
+	return false
+}
+
 func applySubscript(current interface{}, sub pathIndex) (interface{}, bool) {
 	if sub.kind == indexKindUnknown {
 		// Cannot determine statically; assume available.
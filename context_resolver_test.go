@@ -0,0 +1,150 @@
+package renderfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+type resolverUser struct {
+	Name string
+}
+
+type valueLabel struct{ id string }
+
+func (v valueLabel) String() string { return "value " + v.id }
+
+type pointerLabel struct{ id string }
+
+func (p *pointerLabel) String() string { return "pointer " + p.id }
+
+type level int
+
+func (l level) String() string { return "level" }
+
+type bag map[string]int
+
+func (b *bag) String() string { return "bag" }
+
+func TestResolvePathIndexedPointerElements(t *testing.T) {
+	ctx := pongo2.Context{
+		"users":  []*resolverUser{{Name: "alice"}, {Name: "bob"}},
+		"values": []*pongo2.Value{pongo2.AsValue(&resolverUser{Name: "carol"})},
+		"nested": pongo2.AsValue(pongo2.Context{"inner": "x"}),
+	}
+
+	cases := map[string]bool{
+		"users[1].Name":   true,
+		"users[0].Email":  false,
+		"users[2].Name":   false,
+		"values[0].Name":  true,
+		"values[0].Email": false,
+		"nested.inner":    true,
+		"nested.missing":  false,
+	}
+	for path, want := range cases {
+		if got := resolvePath(ctx, path, false); got != want {
+			t.Errorf("resolvePath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestResolvePathMethodReceivers(t *testing.T) {
+	lvl := level(1)
+	items := bag{"a": 1}
+	ctx := pongo2.Context{
+		"value_ptr":   &valueLabel{id: "a"},
+		"value_val":   valueLabel{id: "b"},
+		"pointer_ptr": &pointerLabel{id: "c"},
+		"pointer_val": pointerLabel{id: "d"},
+		"level":       lvl,
+		"level_ptr":   &lvl,
+		"bag_ptr":     &items,
+		"bag_val":     items,
+	}
+
+	// Expectations mirror what pongo2 can call at render time: pointer
+	// receivers are only reachable through a pointer.
+	cases := map[string]bool{
+		"value_ptr.String":   true,
+		"value_val.String":   true,
+		"pointer_ptr.String": true,
+		"pointer_val.String": false,
+		"level.String":       true,
+		"level_ptr.String":   true,
+		"bag_ptr.String":     true,
+		"bag_val.String":     false,
+		"bag_val.a":          true,
+	}
+	for path, want := range cases {
+		if got := resolvePath(ctx, path, false); got != want {
+			t.Errorf("resolvePath(%q) = %v, want %v", path, got, want)
+		}
+
+		tpl := pongo2.Must(pongo2.FromString("{{ " + path + " }}"))
+		out, err := tpl.Execute(ctx)
+		if err != nil {
+			t.Fatalf("render %q: %v", path, err)
+		}
+		if rendered := out != ""; rendered != want {
+			t.Errorf("pongo2 rendered %q as %q, resolvePath disagrees", path, out)
+		}
+	}
+}
+
+func TestResolvePathIndexVariables(t *testing.T) {
+	ctx := pongo2.Context{
+		"items": []*resolverUser{{Name: "alice"}, {Name: "bob"}},
+		"one":   1,
+		"five":  int64(5),
+		"label": "x",
+		"pos":   pongo2.Context{"last": 1},
+	}
+
+	cases := map[string]bool{
+		"items[one]":      true,
+		"items[pos.last]": true,
+		"items[five]":     false,
+		"items[label]":    true, // not an integer; assumed available
+		"items[unknown]":  true, // not resolvable; assumed available
+		"items[one + 1]":  true, // not a simple reference
+	}
+	for path, want := range cases {
+		if got := resolvePath(ctx, path, false); got != want {
+			t.Errorf("resolvePath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestParsePathSplitsEverySegment(t *testing.T) {
+	cases := map[string][]string{
+		"user":               {"user"},
+		"user.profile.name":  {"user", "profile", "name"},
+		"user . profile":     {"user", "profile"},
+		"users[0].name":      {"users", "name"},
+		"a.b[1][2].c":        {"a", "b", "c"},
+		"config['key'].deep": {"config", "deep"},
+	}
+	for path, want := range cases {
+		segments, err := parsePath(path)
+		if err != nil {
+			t.Fatalf("parsePath(%q) failed: %v", path, err)
+		}
+		var got []string
+		for _, segment := range segments {
+			got = append(got, segment.name)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("parsePath(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	segments, err := parsePath("a.b[1][2].c")
+	if err != nil {
+		t.Fatalf("parsePath failed: %v", err)
+	}
+	if n := len(segments[1].subscripts); n != 2 {
+		t.Fatalf("expected 2 subscripts on b, got %d", n)
+	}
+}
@@ -0,0 +1,133 @@
+package renderfs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/your-org/renderfs"
+)
+
+func TestMergeContexts(t *testing.T) {
+	dst := pongo2.Context{
+		"name": "base",
+		"tags": []string{"a", "b"},
+		"db": pongo2.Context{
+			"host": "localhost",
+			"pool": map[string]interface{}{"min": 1, "max": 10},
+		},
+		"feature": pongo2.Context{"enabled": true},
+	}
+	src := pongo2.Context{
+		"name": "override",
+		"tags": []string{"c"},
+		"db": map[string]interface{}{
+			"host": "db.internal",
+			"pool": map[string]interface{}{"max": 50},
+		},
+		"feature": false,
+		"extra":   1,
+	}
+
+	got := renderfs.MergeContexts(dst, src)
+	want := pongo2.Context{
+		"name": "override",
+		"tags": []string{"c"},
+		"db": map[string]interface{}{
+			"host": "db.internal",
+			"pool": map[string]interface{}{"min": 1, "max": 50},
+		},
+		"feature": false,
+		"extra":   1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected merge result:\n got %#v\nwant %#v", got, want)
+	}
+
+	if dst["name"] != "base" || dst["db"].(pongo2.Context)["host"] != "localhost" {
+		t.Fatalf("expected dst to be left untouched, got %#v", dst)
+	}
+	if _, ok := src["db"].(map[string]interface{})["pool"].(map[string]interface{})["min"]; ok {
+		t.Fatalf("expected src to be left untouched, got %#v", src)
+	}
+}
+
+func TestSetContextPath(t *testing.T) {
+	ctx := pongo2.Context{"params": map[string]interface{}{"db": "flat", "name": "app"}}
+
+	sets := []struct {
+		path  string
+		value interface{}
+	}{
+		{"params.db.host", "localhost"},
+		{"params.db.port", 5432},
+		{`servers["eu-west"].port`, 8080},
+		{"debug", true},
+		{"params.name", "renamed"},
+	}
+	for _, set := range sets {
+		if err := renderfs.SetContextPath(ctx, set.path, set.value); err != nil {
+			t.Fatalf("SetContextPath(%q): %v", set.path, err)
+		}
+	}
+
+	want := pongo2.Context{
+		"params": map[string]interface{}{
+			"db":   map[string]interface{}{"host": "localhost", "port": 5432},
+			"name": "renamed",
+		},
+		"servers": map[string]interface{}{"eu-west": map[string]interface{}{"port": 8080}},
+		"debug":   true,
+	}
+	if !reflect.DeepEqual(ctx, want) {
+		t.Fatalf("unexpected context:\n got %#v\nwant %#v", ctx, want)
+	}
+
+	for _, path := range []string{"", "a..b", "items[0]", "items[idx]"} {
+		if err := renderfs.SetContextPath(ctx, path, 1); err == nil {
+			t.Errorf("expected %q to be rejected", path)
+		}
+	}
+	if err := renderfs.SetContextPath(nil, "a", 1); err == nil {
+		t.Errorf("expected nil context to be rejected")
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	ctx := pongo2.Context{"db": map[string]interface{}{"host": "localhost", "port": 5432}}
+	err := renderfs.ApplyOverrides(ctx, []string{
+		"db.port=6543",
+		"db.tls=true",
+		"replicas=-2",
+		`version="1.2.3"`,
+		"motd='a=b.c'",
+		"name=demo",
+		"empty=",
+		"ratio=0.5",
+		`servers["eu.west"].enabled=false`,
+		"name=final",
+	})
+	if err != nil {
+		t.Fatalf("ApplyOverrides failed: %v", err)
+	}
+
+	want := pongo2.Context{
+		"db":       map[string]interface{}{"host": "localhost", "port": 6543, "tls": true},
+		"replicas": -2,
+		"version":  "1.2.3",
+		"motd":     "a=b.c",
+		"name":     "final",
+		"empty":    "",
+		"ratio":    "0.5",
+		"servers":  map[string]interface{}{"eu.west": map[string]interface{}{"enabled": false}},
+	}
+	if !reflect.DeepEqual(ctx, want) {
+		t.Fatalf("unexpected context:\n got %#v\nwant %#v", ctx, want)
+	}
+
+	for _, bad := range []string{"novalue", "=1", `s="unterminated\"`} {
+		if err := renderfs.ApplyOverrides(pongo2.Context{}, []string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -1,140 +1,905 @@
 package renderfs
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/flosch/pongo2/v6"
+	ignore "github.com/sabhiram/go-gitignore"
 )
 
 type statWriter interface {
 	Lstat(path string) (fs.FileInfo, error)
 }
 
+type readWriter interface {
+	ReadFile(path string) ([]byte, error)
+}
+
+type removeWriter interface {
+	Remove(path string) error
+}
+
+// destLstat stats relPath in dest. supported is false when dest cannot
+// report on existing entries: it has no Lstat, or it wraps a writer without
+// one and says so with errors.ErrUnsupported.
+func destLstat(dest Writer, relPath string) (info fs.FileInfo, supported bool, err error) {
+	sw, ok := dest.(statWriter)
+	if !ok {
+		return nil, false, nil
+	}
+	info, err = sw.Lstat(relPath)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, false, nil
+	}
+	return info, true, err
+}
+
+// destReadFile reads relPath back from dest, with supported following the
+// same rules as destLstat.
+func destReadFile(dest Writer, relPath string) (data []byte, supported bool, err error) {
+	rw, ok := dest.(readWriter)
+	if !ok {
+		return nil, false, nil
+	}
+	data, err = rw.ReadFile(relPath)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, false, nil
+	}
+	return data, true, err
+}
+
+type appendWriter interface {
+	AppendFile(path string, perm fs.FileMode) (io.WriteCloser, error)
+}
+
 // Copy walks the source filesystem, renders templates for paths and file
-// contents, and writes the result to the provided Writer.
+// contents, and writes the result to the provided Writer. All output goes
+// through dest, so in-memory, archive, and on-disk writers are handled alike;
+// Skip and Fail conflict handling requires a Writer that also implements
+// Lstat(path string) (fs.FileInfo, error).
 func Copy(source fs.FS, dest Writer, opts Options) error {
+	_, err := CopyWithResult(source, dest, opts)
+	return err
+}
+
+// CopyWithResult behaves like Copy and additionally reports what was written.
+func CopyWithResult(source fs.FS, dest Writer, opts Options) (CopyResult, error) {
 	if source == nil {
-		return fmt.Errorf("renderfs: source filesystem is required")
+		return CopyResult{}, fmt.Errorf("renderfs: source filesystem is required")
 	}
 	if dest == nil {
-		return fmt.Errorf("renderfs: destination writer is required")
+		return CopyResult{}, fmt.Errorf("renderfs: destination writer is required")
+	}
+
+	matcher, err := buildIgnoreMatcher(source, opts)
+	if err != nil {
+		return CopyResult{}, err
+	}
+
+	return stagedCopy(source, dest, opts, matcher, newRenderer(source, opts))
+}
+
+// stagedCopy runs the copy through a stage of dest when opts.Transactional
+// is set, committing it on success and rolling it back otherwise, and runs
+// it against dest directly when not.
+func stagedCopy(source fs.FS, dest Writer, opts Options, matcher *ignore.GitIgnore, r *renderer) (CopyResult, error) {
+	if !opts.Transactional || opts.DryRun {
+		return runCopy(source, dest, opts, matcher, r)
+	}
+
+	stager, ok := dest.(Stager)
+	if !ok {
+		return CopyResult{}, fmt.Errorf("renderfs: destination writer does not support transactional copies")
+	}
+	staged, err := stager.Stage()
+	if err != nil {
+		return CopyResult{}, fmt.Errorf("renderfs: stage destination: %w", err)
+	}
+	result, err := runCopy(source, staged, opts, matcher, r)
+	if err != nil {
+		if rbErr := staged.Rollback(); rbErr != nil {
+			err = errors.Join(err, fmt.Errorf("renderfs: roll back: %w", rbErr))
+		}
+		return result, err
+	}
+	if err := staged.Commit(); err != nil {
+		return result, fmt.Errorf("renderfs: commit: %w", err)
+	}
+	return result, nil
+}
+
+// runCopy performs the walk with an already compiled ignore matcher and a
+// renderer bound to source.
+func runCopy(source fs.FS, dest Writer, opts Options, matcher *ignore.GitIgnore, r *renderer) (CopyResult, error) {
+	c, err := newCopier(source, dest, opts, matcher, r)
+	if err != nil {
+		return CopyResult{}, err
+	}
+
+	if opts.PrecompileTemplates {
+		if err := c.precompileAll(); err != nil {
+			return c.result, err
+		}
 	}
 
+	// Directories are created in a pass of their own, so each has its final
+	// mode before any file or link is written into it.
+	c.dirsOnly = true
+	if err := fs.WalkDir(source, ".", c.visit); err != nil {
+		return c.result, err
+	}
+	c.dirsOnly = false
+	if err := fs.WalkDir(source, ".", c.visit); err != nil {
+		return c.result, err
+	}
+
+	if c.manifest != nil {
+		if err := writeManifest(opts.ManifestWriter, c.manifest); err != nil {
+			return c.result, err
+		}
+	}
+	if opts.AfterCopy != nil {
+		if err := opts.AfterCopy(c.result, opts.DryRun); err != nil {
+			return c.result, fmt.Errorf("renderfs: after copy: %w", err)
+		}
+	}
+	return c.result, nil
+}
+
+// newCopier prepares the state for one copy, including the prior manifest
+// and, when ExposeFileList is set, the path-only pass.
+func newCopier(source fs.FS, dest Writer, opts Options, matcher *ignore.GitIgnore, r *renderer) (*copier, error) {
+	opts = NormalizeOptions(opts)
+	base := effectiveContext(opts)
+	_, customRelpath := base["relpath"]
+	context := withBuiltins(base, source, opts)
+
+	c := &copier{
+		source:      source,
+		dest:        dest,
+		opts:        opts,
+		context:     context,
+		pathContext: context,
+		conflict:    opts.OnConflict,
+		matcher:     matcher,
+		renderer:    r,
+		bindRelpath: !customRelpath,
+		contentData: make(map[string]pongo2.Context),
+		pathData:    make(map[string]pongo2.Context),
+		conditions:  make(map[string]bool),
+		dirs:        make(map[string]struct{}),
+
+		renderedMatcher: compilePatterns(opts.IgnoreRenderedPatterns),
+		verbatimMatcher: compilePatterns(opts.VerbatimPatterns),
+	}
+	if len(opts.Redirects) > 0 {
+		redirects, err := compileRedirects(opts.Redirects)
+		if err != nil {
+			return nil, err
+		}
+		c.redirects = redirects
+	}
+	if len(opts.Rename) > 0 {
+		c.rename = renameMapper(opts.Rename)
+	}
+	if opts.PathContext != nil {
+		c.pathContext = memoizeLazy(opts.PathContext)
+	}
+	if opts.ManifestWriter != nil {
+		c.manifest = make(map[string]string)
+	}
+	if opts.PriorManifest != nil {
+		prior, err := readManifest(opts.PriorManifest)
+		if err != nil {
+			return nil, err
+		}
+		c.prior = prior
+	}
+
+	if opts.ExposeFileList {
+		files, err := c.listFiles()
+		if err != nil {
+			return nil, err
+		}
+		c.context = withOverrides(c.context, map[string]interface{}{fileListKey: files})
+	}
+	return c, nil
+}
+
+// effectiveContext returns Context layered over ContextChain, never nil, with
+// lazy values memoized for the duration of one copy.
+func effectiveContext(opts Options) pongo2.Context {
 	context := opts.Context
+	if len(opts.ContextChain) > 0 {
+		context = layerContexts(append([]pongo2.Context{opts.Context}, opts.ContextChain...)...)
+	}
 	if context == nil {
-		context = pongo2.Context{}
+		return pongo2.Context{}
+	}
+	return memoizeLazy(context)
+}
+
+// copier holds the state of a single Copy invocation.
+type copier struct {
+	source   fs.FS
+	dest     Writer
+	opts     Options
+	context  pongo2.Context
+	conflict ConflictResolution
+	matcher  *ignore.GitIgnore
+	renderer *renderer
+	manifest map[string]string
+	prior    map[string]string
+	result   CopyResult
+
+	renderedMatcher *ignore.GitIgnore
+	verbatimMatcher *ignore.GitIgnore
+
+	// pathContext renders relative paths; it is context unless
+	// Options.PathContext is set.
+	pathContext pongo2.Context
+
+	// redirects holds the cleaned Options.Redirects.
+	redirects map[string]string
+
+	rename func(string, bool) (string, bool, error)
+
+	// contentData and pathData cache, per source directory, context and
+	// pathContext layered with the directory's data files.
+	contentData map[string]pongo2.Context
+	pathData    map[string]pongo2.Context
+
+	// conditions caches, per source directory, whether its condition file
+	// skips the directory's contents.
+	conditions map[string]bool
+
+	// listOnly makes the walk record destination file paths in listed
+	// instead of writing anything.
+	listOnly    bool
+	listed      []string
+	listedLinks int // entries of listed copied as links rather than files
+
+	// flattened maps each name written by a flattened copy to its source.
+	flattened map[string]string
+
+	// dirsOnly makes the walk create directories, including those reached
+	// through followed links, and nothing else.
+	dirsOnly bool
+
+	// dirs records the rendered directories created from source entries, so
+	// writing a file into one does not reset its mode.
+	dirs map[string]struct{}
+
+	// bindRelpath reports that the relpath builtin is not shadowed by the
+	// context and should measure from each rendered file.
+	bindRelpath bool
+}
+
+func (c *copier) visit(rel string, d fs.DirEntry, walkErr error) error {
+	if walkErr != nil {
+		return walkErr
+	}
+	if rel == "." {
+		return nil
+	}
+	skipAs := func(reason SkipReason) error {
+		c.skipped(rel, reason)
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	}
+	if c.opts.MaxDepth > 0 && strings.Count(rel, "/")+1 > c.opts.MaxDepth {
+		return skipAs(SkipMaxDepth)
+	}
+
+	if isIgnored(c.matcher, rel, d.IsDir(), c.opts.IncludeIgnoreFile) {
+		return skipAs(SkipIgnored)
+	}
+	if c.opts.DirectoryConditions && !d.IsDir() && path.Base(rel) == conditionFileName {
+		return nil
+	}
+	if skip, err := c.childrenSkipped(path.Dir(rel)); err != nil || skip {
+		if err != nil {
+			return err
+		}
+		return skipAs(SkipCondition)
+	}
+	if c.dirsOnly && !d.IsDir() && (d.Type()&fs.ModeSymlink == 0 || !c.opts.FollowSymlinks) {
+		return nil
+	}
+	if c.opts.DirectoryData && !d.IsDir() && path.Base(rel) == dataFileName {
+		return nil
+	}
+
+	if d.IsDir() && c.opts.StrictSuffix {
+		if suffix := templateSuffix(path.Base(rel)); suffix != "" {
+			return fmt.Errorf("renderfs: directory %s has template suffix %s", rel, suffix)
+		}
 	}
 
-	conflict := opts.OnConflict
-	if conflict < Overwrite || conflict > Fail {
-		conflict = Overwrite
+	info, err := d.Info()
+	if err != nil {
+		return fmt.Errorf("renderfs: stat %s: %w", rel, err)
 	}
 
-	matcher, err := buildIgnoreMatcher(source, opts.IgnorePatterns)
+	pathContext, err := c.dirContext(path.Dir(rel), c.pathContext, c.pathData)
 	if err != nil {
 		return err
 	}
+	verbatim := c.verbatim(rel, d.IsDir())
+	renderedRel, skip := rel, false
+	if !verbatim {
+		renderedRel, skip, err = c.renderer.renderRelativePath(c.pathTemplate(rel, d.IsDir()), d.IsDir(), pathContext)
+		if err != nil {
+			return templateError(err, "render path", rel, 0)
+		}
+	}
+	if skip {
+		return skipAs(SkipEmptyPath)
+	}
+	if d.Type().IsRegular() && !verbatim {
+		if suffix, _ := c.contentDecoder(rel); suffix != "" && path.Base(renderedRel) != suffix {
+			renderedRel = strings.TrimSuffix(renderedRel, suffix)
+		}
+	}
+	if c.rename != nil {
+		renderedRel, skip, err = mapPath(c.rename, renderedRel, d.IsDir())
+		if err != nil {
+			return fmt.Errorf("renderfs: rename %s: %w", rel, err)
+		}
+	}
+	if !skip && c.opts.PathMapper != nil {
+		renderedRel, skip, err = mapPath(c.opts.PathMapper, renderedRel, d.IsDir())
+		if err != nil {
+			return fmt.Errorf("renderfs: map path %s: %w", rel, err)
+		}
+	}
+	if skip {
+		return skipAs(SkipFiltered)
+	}
+	if matchesEntry(c.renderedMatcher, renderedRel, d.IsDir()) {
+		return skipAs(SkipIgnored)
+	}
+	if err := c.checkNameLength(rel, renderedRel); err != nil {
+		return err
+	}
 
-	return fs.WalkDir(source, ".", func(rel string, d fs.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
+	if d.IsDir() {
+		return c.makeDir(rel, renderedRel, info)
+	}
+	if c.opts.Flatten {
+		if path.Base(rel) == c.opts.KeepMarker {
+			return skipAs(SkipFiltered)
 		}
-		if rel == "." {
-			return nil
+		if renderedRel, err = c.flatten(rel, renderedRel); err != nil {
+			return err
 		}
+	}
 
-		if matcher != nil && matcher.MatchesPath(rel) {
-			if d.IsDir() {
-				return fs.SkipDir
-			}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if c.opts.FollowSymlinks {
+			return c.followSymlink(rel, renderedRel)
+		}
+		return c.copySymlink(rel, renderedRel)
+	}
+
+	if c.opts.DropKeepMarker && path.Base(rel) == c.opts.KeepMarker {
+		// The enclosing directory entry has already been created by the walk.
+		return skipAs(SkipFiltered)
+	}
+
+	return c.copyFile(rel, renderedRel, info)
+}
+
+// checkNameLength rejects a rendered path with an element longer than
+// Options.MaxNameLength.
+func (c *copier) checkNameLength(rel, renderedRel string) error {
+	limit := c.opts.MaxNameLength
+	if limit < 0 {
+		return nil
+	}
+	for _, name := range strings.Split(renderedRel, "/") {
+		if len(name) > limit {
+			return fmt.Errorf("renderfs: rendered path for %s has a %d-byte name %q, exceeding the limit of %d", rel, len(name), name, limit)
+		}
+	}
+	return nil
+}
+
+func (c *copier) makeDir(rel, renderedRel string, info fs.FileInfo) error {
+	if !c.dirsOnly || c.opts.Flatten {
+		return nil
+	}
+	mode := directoryMode(info)
+	if c.opts.DirModeFunc != nil {
+		mode = c.opts.DirModeFunc(rel, info)
+	}
+	if override, ok := lookupGlob(c.opts.DirModeOverrides, renderedRel); ok {
+		mode = override
+	}
+	if c.opts.DryRun || c.listOnly {
+		return nil
+	}
+	if err := c.dest.MkdirAll(renderedRel, mode); err != nil {
+		return err
+	}
+	c.dirs[renderedRel] = struct{}{}
+	return nil
+}
+
+// flatten returns the base name of renderedRel for Options.Flatten and
+// reports a second source entry flattening to the same name.
+func (c *copier) flatten(rel, renderedRel string) (string, error) {
+	name := path.Base(renderedRel)
+	if c.listOnly || c.dirsOnly {
+		return name, nil
+	}
+	if c.flattened == nil {
+		c.flattened = make(map[string]string)
+	}
+	if other, ok := c.flattened[name]; ok {
+		return "", fmt.Errorf("renderfs: %s and %s both flatten to %s", other, rel, name)
+	}
+	c.flattened[name] = rel
+	return name, nil
+}
+
+// followSymlink copies what the link at rel points to. File targets are
+// rendered like regular files; directory targets are walked in place, so their
+// entries are rendered under the link's path. A directory link that leads back
+// to one of its own ancestors is skipped, or reported when FailOnSymlinkLoop
+// is set.
+func (c *copier) followSymlink(rel, renderedRel string) error {
+	real, err := resolveLinks(c.source, rel)
+	if err != nil {
+		return fmt.Errorf("renderfs: resolve symlink %s: %w", rel, err)
+	}
+	info, err := fs.Stat(c.source, real)
+	if err != nil {
+		return fmt.Errorf("renderfs: stat symlink target %s: %w", rel, err)
+	}
+	if !info.IsDir() {
+		if c.dirsOnly {
 			return nil
 		}
+		return c.copyFile(rel, renderedRel, info)
+	}
 
-		if rel == ".renderfs-ignore" {
-			if d.IsDir() {
-				return fs.SkipDir
-			}
+	loops, err := c.entersAncestor(rel, real)
+	if err != nil {
+		return err
+	}
+	if loops {
+		if c.opts.FailOnSymlinkLoop {
+			return fmt.Errorf("renderfs: symlink %s loops back to %s", rel, real)
+		}
+		c.skipped(rel, SkipSymlinkLoop)
+		return nil
+	}
+
+	if err := c.makeDir(rel, renderedRel, info); err != nil {
+		return err
+	}
+	return fs.WalkDir(c.source, rel, func(p string, d fs.DirEntry, walkErr error) error {
+		if p == rel && walkErr == nil {
 			return nil
 		}
+		return c.visit(p, d, walkErr)
+	})
+}
 
-		info, err := d.Info()
+// entersAncestor reports whether real, the resolved target of the directory
+// link at rel, is also the resolved path of a directory enclosing rel. Walking
+// such a link would never terminate.
+func (c *copier) entersAncestor(rel, real string) (bool, error) {
+	for dir := path.Dir(rel); ; dir = path.Dir(dir) {
+		resolved, err := resolveLinks(c.source, dir)
 		if err != nil {
-			return fmt.Errorf("renderfs: stat %s: %w", rel, err)
+			return false, fmt.Errorf("renderfs: resolve %s: %w", dir, err)
+		}
+		if resolved == real {
+			return true, nil
+		}
+		if dir == "." {
+			return false, nil
+		}
+	}
+}
+
+// maxSymlinkHops bounds link resolution, matching common OS limits.
+const maxSymlinkHops = 40
+
+// resolveLinks resolves every symlink along rel within source and returns the
+// link-free path it refers to. Targets that are absolute or climb above the
+// source root cannot be resolved within an fs.FS and are rejected.
+func resolveLinks(source fs.FS, rel string) (string, error) {
+	resolved := "."
+	pending := strings.Split(rel, "/")
+	for hops := 0; len(pending) > 0; {
+		next := path.Join(resolved, pending[0])
+		pending = pending[1:]
+		if escapesRoot(next) {
+			return "", fmt.Errorf("symlink leads outside the source")
+		}
+		if next == resolved {
+			continue
 		}
 
-		renderedRel, skip, err := renderRelativePath(rel, d.IsDir(), context)
+		info, err := fs.Lstat(source, next)
 		if err != nil {
-			return fmt.Errorf("renderfs: render path %s: %w", rel, err)
+			return "", err
 		}
-		if skip {
-			if d.IsDir() {
-				return fs.SkipDir
-			}
-			return nil
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
 		}
 
-		if d.IsDir() {
-			return dest.MkdirAll(renderedRel, directoryMode(info))
+		if hops++; hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many levels of symbolic links at %s", next)
+		}
+		target, err := readSymlink(source, next)
+		if err != nil {
+			return "", err
+		}
+		if isAbsolutePath(target) {
+			return "", fmt.Errorf("symlink %s has absolute target %q", next, target)
 		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return resolved, nil
+}
 
-		if info.Mode()&fs.ModeSymlink != 0 {
-			target, err := readSymlink(source, rel)
-			if err != nil {
-				return fmt.Errorf("renderfs: read symlink %s: %w", rel, err)
-			}
-			if err := dest.Symlink(target, renderedRel); err != nil {
-				return fmt.Errorf("renderfs: create symlink %s -> %s: %w", renderedRel, target, err)
-			}
-			return nil
+func (c *copier) copySymlink(rel, renderedRel string) error {
+	if c.listOnly {
+		c.listed = append(c.listed, renderedRel)
+		c.listedLinks++
+		return nil
+	}
+	target, err := readSymlink(c.source, rel)
+	if err != nil {
+		return fmt.Errorf("renderfs: read symlink %s: %w", rel, err)
+	}
+	if c.opts.RenderSymlinkTargets && !c.verbatim(rel, false) {
+		ctx, err := c.dirContext(path.Dir(rel), c.context, c.contentData)
+		if err != nil {
+			return err
+		}
+		target, err = c.renderer.renderSymlinkTarget(target, renderedRel, ctx)
+		if err != nil {
+			return templateError(err, "render symlink target", rel, 0)
 		}
+	}
+	if c.opts.DryRun {
+		return nil
+	}
+	if err := c.dest.Symlink(target, renderedRel); err != nil {
+		return fmt.Errorf("renderfs: create symlink %s -> %s: %w", renderedRel, target, err)
+	}
+	return nil
+}
 
-		proceed, err := handleConflict(dest, renderedRel, conflict)
+// sourceKey names the context variable that holds a file's raw source when
+// Options.ExposeSource is set.
+const sourceKey = "__source__"
+
+func (c *copier) copyFile(rel, renderedRel string, info fs.FileInfo) error {
+	if c.verbatim(rel, false) {
+		return c.copyVerbatim(rel, renderedRel, info)
+	}
+	if limit := c.opts.MaxSourceFileSize; limit > 0 && info.Size() > limit {
+		if c.opts.FailOnOversizedSource {
+			return fmt.Errorf("renderfs: source %s is %d bytes, exceeding limit of %d", rel, info.Size(), limit)
+		}
+		if !c.listOnly {
+			c.result.SkippedOversized++
+		}
+		c.skipped(rel, SkipOversized)
+		return nil
+	}
+	if c.listOnly {
+		c.listed = append(c.listed, renderedRel)
+		return nil
+	}
+	if keep, err := c.keepOnceFile(renderedRel); err != nil || keep {
+		if keep {
+			c.skipped(rel, SkipOnce)
+		}
+		return err
+	}
+	appending := c.appends(renderedRel)
+	var merge MergeFunc
+	var existing []byte
+	if !appending {
+		var err error
+		merge, existing, err = c.pendingMerge(renderedRel)
+		if err != nil {
+			return err
+		}
+	}
+	if merge == nil && !appending {
+		proceed, err := c.handleConflict(renderedRel)
 		if err != nil {
 			return err
 		}
 		if !proceed {
+			c.skipped(rel, SkipConflict)
 			return nil
 		}
 
-		if parent := path.Dir(renderedRel); parent != "." {
-			if err := dest.MkdirAll(parent, 0o755); err != nil {
-				return fmt.Errorf("renderfs: create parent %s: %w", parent, err)
+		if _, decode := c.contentDecoder(rel); decode == nil {
+			streamed, err := c.streamPlainFile(rel, renderedRel, info)
+			if err != nil || streamed {
+				return err
 			}
 		}
+	}
+
+	content, err := c.readSource(rel)
+	if err != nil {
+		return err
+	}
 
-		content, err := fs.ReadFile(source, rel)
+	ctx, err := c.dirContext(path.Dir(rel), c.context, c.contentData)
+	if err != nil {
+		return err
+	}
+	if c.opts.ExposeSource {
+		ctx = withOverrides(ctx, map[string]interface{}{sourceKey: string(content)})
+	}
+	mode := fileMode(info)
+	raw := content
+	declared, body, ok, err := splitModeDirective(content)
+	if err != nil {
+		return fmt.Errorf("renderfs: %s: %w", rel, err)
+	}
+	if ok {
+		mode = declared
+		content = body
+	}
+	if c.bindRelpath && bytes.Contains(content, []byte("relpath")) {
+		ctx = withOverrides(ctx, map[string]interface{}{"relpath": relativePath(renderedRel)})
+	}
+	if c.opts.FrontMatter {
+		values, body, ok, err := splitFrontMatter(content)
 		if err != nil {
-			return fmt.Errorf("renderfs: read %s: %w", rel, err)
+			return fmt.Errorf("renderfs: %s: %w", rel, err)
 		}
+		if ok {
+			ctx = withOverrides(ctx, values)
+			content = body
+		}
+	}
+
+	started := time.Now()
+	renderedContent, err := c.renderer.renderTemplateString(string(content), ctx)
+	if err != nil {
+		return templateError(err, "render file", rel, strippedLines(raw, content))
+	}
+	if c.opts.OnFileRendered != nil {
+		c.opts.OnFileRendered(rel, time.Since(started), len(renderedContent))
+	}
+	if c.opts.SkipEmptyFiles && strings.TrimSpace(renderedContent) == "" {
+		c.result.SkippedEmpty++
+		c.skipped(rel, SkipEmptyContent)
+		return nil
+	}
+	if limit := c.opts.MaxRenderedSize; limit > 0 && int64(len(renderedContent)) > limit {
+		return fmt.Errorf("renderfs: rendered %s is %d bytes, exceeding limit of %d", rel, len(renderedContent), limit)
+	}
+	rendered := []byte(renderedContent)
 
-		renderedContent, err := renderTemplateString(string(content), context)
+	if transform := c.opts.Transformers[path.Ext(renderedRel)]; transform != nil {
+		rendered, err = transform(rendered)
 		if err != nil {
-			return fmt.Errorf("renderfs: render file %s: %w", rel, err)
+			return fmt.Errorf("renderfs: transform %s: %w", renderedRel, err)
+		}
+	}
+
+	if c.opts.ValidateStructured {
+		if err := validateStructured(renderedRel, rendered); err != nil {
+			return err
 		}
+	}
+
+	if c.opts.LineEndings != PreserveLineEndings && !isBinary(rendered) {
+		rendered = normalizeLineEndings(rendered, c.opts.LineEndings)
+	}
+	if c.opts.EnsureFinalNewline && len(rendered) > 0 && !bytes.HasSuffix(rendered, []byte("\n")) && !isBinary(rendered) {
+		rendered = appendNewline(rendered, c.opts.LineEndings)
+	}
 
-		handle, err := dest.CreateFile(renderedRel, fileMode(info))
+	if merge != nil {
+		rendered, err = merge(existing, rendered)
+		if err != nil {
+			return fmt.Errorf("renderfs: merge %s: %w", renderedRel, err)
+		}
+	}
+
+	if appending {
+		return c.writeFile(rel, renderedRel, mode, bytes.NewReader(rendered))
+	}
+
+	var sum string
+	if c.manifest != nil || c.prior != nil {
+		sum = checksum(rendered)
+	}
+	if c.manifest != nil {
+		c.manifest[renderedRel] = sum
+	}
+
+	if c.unchangedSincePrior(renderedRel, sum) ||
+		(c.opts.SkipUnchanged && destinationUnchanged(c.dest, renderedRel, rendered, mode)) {
+		c.result.SkippedUnchanged++
+		c.skipped(rel, SkipUnchanged)
+		return nil
+	}
+
+	return c.writeFile(rel, renderedRel, mode, bytes.NewReader(rendered))
+}
+
+// created reports whether dir was created from a source directory entry.
+func (c *copier) created(dir string) bool {
+	_, ok := c.dirs[dir]
+	return ok
+}
+
+// appends reports whether renderedRel matches Options.AppendGlobs.
+func (c *copier) appends(renderedRel string) bool {
+	for _, pattern := range c.opts.AppendGlobs {
+		if matchGlob(pattern, renderedRel) {
+			return true
+		}
+	}
+	return false
+}
+
+// open creates renderedRel at the destination, or opens it for appending
+// when it matches Options.AppendGlobs.
+func (c *copier) open(renderedRel string, mode fs.FileMode) (io.WriteCloser, error) {
+	if !c.appends(renderedRel) {
+		return c.dest.CreateFile(renderedRel, mode)
+	}
+	aw, ok := c.dest.(appendWriter)
+	if !ok {
+		return nil, fmt.Errorf("destination writer does not support appending")
+	}
+	return aw.AppendFile(renderedRel, mode)
+}
+
+// writeFile creates renderedRel at the destination with content from r and
+// reports it to Options.OnFile. In a dry run the content is only measured.
+func (c *copier) writeFile(rel, renderedRel string, mode fs.FileMode, r io.Reader) error {
+	var size int64
+	if c.opts.DryRun {
+		n, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return fmt.Errorf("renderfs: read %s: %w", rel, err)
+		}
+		size = n
+	} else {
+		// Parents without a source entry of their own, such as those
+		// introduced by a rendered path, default to 0o755.
+		if parent := path.Dir(renderedRel); parent != "." && !c.created(parent) {
+			if err := c.dest.MkdirAll(parent, 0o755); err != nil {
+				return fmt.Errorf("renderfs: create parent %s: %w", parent, err)
+			}
+		}
+
+		handle, err := c.open(renderedRel, mode)
 		if err != nil {
 			return fmt.Errorf("renderfs: create %s: %w", renderedRel, err)
 		}
-		if _, err := io.WriteString(handle, renderedContent); err != nil {
+		n, err := io.Copy(handle, r)
+		if err != nil {
 			handle.Close()
 			return fmt.Errorf("renderfs: write %s: %w", renderedRel, err)
 		}
 		if err := handle.Close(); err != nil {
 			return fmt.Errorf("renderfs: close %s: %w", renderedRel, err)
 		}
+		size = n
+	}
 
-		return nil
-	})
+	c.result.FilesWritten++
+	if c.opts.OnFile != nil {
+		event := FileEvent{Source: rel, Path: renderedRel, Mode: mode, Size: size, DryRun: c.opts.DryRun}
+		if err := c.opts.OnFile(event); err != nil {
+			return fmt.Errorf("renderfs: on file %s: %w", renderedRel, err)
+		}
+	}
+	return nil
+}
+
+// unchangedSincePrior reports whether the prior manifest recorded the same
+// checksum for renderedRel and the file still exists at the destination.
+func (c *copier) unchangedSincePrior(renderedRel, sum string) bool {
+	if prior, ok := c.prior[renderedRel]; !ok || prior != sum {
+		return false
+	}
+	if _, supported, err := destLstat(c.dest, renderedRel); supported && err != nil {
+		return false
+	}
+	return true
 }
 
-func renderRelativePath(rel string, isDir bool, ctx pongo2.Context) (string, bool, error) {
-	rendered, err := renderTemplateString(rel, ctx)
+// keepOnceFile reports whether renderedRel matches Options.OnceGlobs and
+// already exists at the destination, in which case it must be left alone.
+func (c *copier) keepOnceFile(renderedRel string) (bool, error) {
+	matched := false
+	for _, pattern := range c.opts.OnceGlobs {
+		if matchGlob(pattern, renderedRel) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false, nil
+	}
+
+	_, supported, err := destLstat(c.dest, renderedRel)
+	if !supported {
+		return false, fmt.Errorf("renderfs: destination writer does not support conflict detection for %s", renderedRel)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("renderfs: stat destination %s: %w", renderedRel, err)
+	}
+	return true, nil
+}
+
+// pendingMerge returns the merge function and current destination content
+// when renderedRel matches Options.MergeFuncs and already exists as a regular
+// file. A nil MergeFunc means normal conflict handling applies.
+func (c *copier) pendingMerge(renderedRel string) (MergeFunc, []byte, error) {
+	merge, ok := lookupGlob(c.opts.MergeFuncs, renderedRel)
+	if !ok || merge == nil {
+		return nil, nil, nil
+	}
+	info, supported, err := destLstat(c.dest, renderedRel)
+	if !supported {
+		return nil, nil, nil
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("renderfs: stat destination %s: %w", renderedRel, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil, nil
+	}
+
+	existing, supported, err := destReadFile(c.dest, renderedRel)
+	if !supported {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("renderfs: read destination %s: %w", renderedRel, err)
+	}
+	return merge, existing, nil
+}
+
+// pathTemplate returns the template rendered for the destination path of
+// rel: its redirect target if it has one, or rel itself.
+func (c *copier) pathTemplate(rel string, isDir bool) string {
+	if target, ok := c.redirects[rel]; ok && !isDir {
+		return target
+	}
+	return rel
+}
+
+func (r *renderer) renderRelativePath(rel string, isDir bool, ctx pongo2.Context) (string, bool, error) {
+	rendered, err := r.renderTemplateString(rel, ctx)
 	if err != nil {
 		return "", false, err
 	}
@@ -150,7 +915,7 @@ func renderRelativePath(rel string, isDir bool, ctx pongo2.Context) (string, boo
 		return "", true, nil
 	}
 
-	if strings.HasPrefix(clean, "../") || strings.HasPrefix(clean, "/") {
+	if escapesRoot(clean) {
 		return "", false, fmt.Errorf("renderfs: rendered path %q escapes destination", rendered)
 	}
 
@@ -161,27 +926,86 @@ func renderRelativePath(rel string, isDir bool, ctx pongo2.Context) (string, boo
 	return clean, false, nil
 }
 
-func stripTemplateSuffix(p string) string {
-	switch {
-	case strings.HasSuffix(p, ".jinja"):
-		return strings.TrimSuffix(p, ".jinja")
-	case strings.HasSuffix(p, ".tmpl"):
-		return strings.TrimSuffix(p, ".tmpl")
-	default:
-		return p
+// mapPath applies mapper to a rendered path and validates the result the same
+// way rendered paths are validated. An empty result skips the entry.
+func mapPath(mapper func(string, bool) (string, bool, error), renderedRel string, isDir bool) (string, bool, error) {
+	mapped, skip, err := mapper(renderedRel, isDir)
+	if err != nil || skip {
+		return "", skip, err
+	}
+
+	mapped = strings.ReplaceAll(strings.TrimSpace(mapped), "\\", "/")
+	if mapped == "" {
+		return "", true, nil
+	}
+	clean := path.Clean(mapped)
+	if clean == "." {
+		return "", true, nil
+	}
+	if escapesRoot(clean) {
+		return "", false, fmt.Errorf("renderfs: mapped path %q escapes destination", mapped)
 	}
+	return clean, false, nil
 }
 
-func handleConflict(dest Writer, relPath string, resolution ConflictResolution) (bool, error) {
-	sw, ok := dest.(statWriter)
-	if !ok {
+func (r *renderer) renderSymlinkTarget(target, linkRel string, ctx pongo2.Context) (string, error) {
+	rendered, err := r.renderTemplateString(target, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	rendered = strings.ReplaceAll(strings.TrimSpace(rendered), "\\", "/")
+	if rendered == "" {
+		return "", fmt.Errorf("renderfs: symlink target for %s rendered empty", linkRel)
+	}
+	if isAbsolutePath(rendered) || escapesRoot(path.Join(path.Dir(linkRel), rendered)) {
+		return "", fmt.Errorf("renderfs: symlink target %q escapes destination", rendered)
+	}
+	return rendered, nil
+}
+
+// escapesRoot reports whether a cleaned, slash-separated path leaves the
+// destination root, either by climbing above it or by being absolute.
+func escapesRoot(clean string) bool {
+	return clean == ".." || strings.HasPrefix(clean, "../") || isAbsolutePath(clean)
+}
+
+// isAbsolutePath reports whether a slash-separated path is absolute on any
+// platform: rooted POSIX paths, UNC shares (//host/share), and Windows drive
+// paths (C:/x or drive-relative C:x). Templates are rendered on every OS, so
+// the check does not depend on the host.
+func isAbsolutePath(p string) bool {
+	if strings.HasPrefix(p, "/") {
+		return true
+	}
+	return len(p) >= 2 && p[1] == ':' && ((p[0] >= 'a' && p[0] <= 'z') || (p[0] >= 'A' && p[0] <= 'Z'))
+}
+
+var templateSuffixes = []string{".jinja", ".tmpl"}
+
+// templateSuffix returns the template suffix carried by p, or "" when none.
+func templateSuffix(p string) string {
+	for _, suffix := range templateSuffixes {
+		if strings.HasSuffix(p, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+func stripTemplateSuffix(p string) string {
+	return strings.TrimSuffix(p, templateSuffix(p))
+}
+
+func (c *copier) handleConflict(relPath string) (bool, error) {
+	resolution := c.conflict
+	info, supported, err := destLstat(c.dest, relPath)
+	if !supported {
 		if resolution == Skip || resolution == Fail {
 			return false, fmt.Errorf("renderfs: destination writer does not support conflict detection for %s", relPath)
 		}
 		return true, nil
 	}
-
-	info, err := sw.Lstat(relPath)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return true, nil
@@ -192,6 +1016,9 @@ func handleConflict(dest Writer, relPath string, resolution ConflictResolution)
 	if info.IsDir() {
 		return false, fmt.Errorf("renderfs: destination %s is a directory", relPath)
 	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return c.replaceSymlink(relPath)
+	}
 
 	switch resolution {
 	case Skip:
@@ -203,6 +1030,33 @@ func handleConflict(dest Writer, relPath string, resolution ConflictResolution)
 	}
 }
 
+// replaceSymlink applies resolution to an existing destination symlink.
+// Writing through the link would modify its target, possibly outside the
+// destination, so Overwrite removes the link first.
+func (c *copier) replaceSymlink(relPath string) (bool, error) {
+	switch c.conflict {
+	case Skip:
+		return false, nil
+	case Fail:
+		return false, fmt.Errorf("renderfs: destination %s is a symlink", relPath)
+	}
+
+	rw, ok := c.dest.(removeWriter)
+	if !ok {
+		return false, fmt.Errorf("renderfs: destination writer cannot replace symlink %s", relPath)
+	}
+	if c.opts.DryRun {
+		return true, nil
+	}
+	if err := rw.Remove(relPath); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return false, fmt.Errorf("renderfs: destination writer cannot replace symlink %s", relPath)
+		}
+		return false, fmt.Errorf("renderfs: remove symlink %s: %w", relPath, err)
+	}
+	return true, nil
+}
+
 func directoryMode(info fs.FileInfo) fs.FileMode {
 	perm := fs.FileMode(0o755)
 	if info != nil {
@@ -229,3 +1083,22 @@ func readSymlink(source fs.FS, rel string) (string, error) {
 	}
 	return "", fmt.Errorf("renderfs: source filesystem does not support symlinks")
 }
+
+// destinationUnchanged reports whether the destination already holds a regular
+// file with identical content and permissions. Writers that cannot be read
+// back are always considered changed.
+func destinationUnchanged(dest Writer, relPath string, content []byte, mode fs.FileMode) bool {
+	info, supported, err := destLstat(dest, relPath)
+	if !supported || err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if info.Size() != int64(len(content)) || info.Mode().Perm() != mode.Perm() {
+		return false
+	}
+
+	existing, supported, err := destReadFile(dest, relPath)
+	if !supported || err != nil {
+		return false
+	}
+	return bytes.Equal(existing, content)
+}
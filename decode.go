@@ -0,0 +1,57 @@
+package renderfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// contentDecoder returns the Options.ContentDecoders suffix carried by the
+// source file rel, ignoring any template suffix after it, together with its
+// decoder. The longest matching suffix wins.
+func (c *copier) contentDecoder(rel string) (string, func(io.Reader) (io.Reader, error)) {
+	name := stripTemplateSuffix(rel)
+	var suffix string
+	for candidate := range c.opts.ContentDecoders {
+		if candidate != "" && len(candidate) > len(suffix) && strings.HasSuffix(name, candidate) {
+			suffix = candidate
+		}
+	}
+	if suffix == "" {
+		return "", nil
+	}
+	return suffix, c.opts.ContentDecoders[suffix]
+}
+
+// readSource returns the content of the source file rel, decoded when its
+// name carries a ContentDecoders suffix.
+func (c *copier) readSource(rel string) ([]byte, error) {
+	_, decode := c.contentDecoder(rel)
+	if decode == nil {
+		content, err := fs.ReadFile(c.source, rel)
+		if err != nil {
+			return nil, fmt.Errorf("renderfs: read %s: %w", rel, err)
+		}
+		return content, nil
+	}
+
+	f, err := c.source.Open(rel)
+	if err != nil {
+		return nil, fmt.Errorf("renderfs: read %s: %w", rel, err)
+	}
+	defer f.Close()
+
+	r, err := decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("renderfs: decode %s: %w", rel, err)
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("renderfs: decode %s: %w", rel, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,268 @@
+package renderfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DiffStatus classifies a rendered file against the destination.
+type DiffStatus int
+
+const (
+	// DiffNew marks a file that does not exist at the destination yet.
+	DiffNew DiffStatus = iota
+	// DiffModified marks a file whose destination content differs.
+	DiffModified
+	// DiffUnchanged marks a file whose destination content already matches.
+	DiffUnchanged
+)
+
+// FileDiff describes how one rendered file compares to the destination.
+type FileDiff struct {
+	// Path is the destination-relative, slash-separated path.
+	Path string
+
+	// Status reports whether the file is new, modified, or unchanged.
+	Status DiffStatus
+
+	// Diff holds a unified diff from the existing to the rendered content
+	// when Status is DiffModified.
+	Diff string
+}
+
+// CopyDiff renders source as Copy would and compares every file it would
+// write against the existing tree at destPath, without writing anything. The
+// result is sorted by path. Conflict handling and merge functions are applied
+// as in Copy, so files Copy would skip are not reported; directories and
+// symlinks are not compared.
+func CopyDiff(source fs.FS, destPath string, opts Options) ([]FileDiff, error) {
+	if source == nil {
+		return nil, fmt.Errorf("renderfs: source filesystem is required")
+	}
+
+	matcher, err := buildIgnoreMatcher(source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every file must reach the capture so it can be classified here.
+	opts.ManifestWriter = nil
+	opts.PriorManifest = nil
+	opts.SkipUnchanged = false
+
+	capture := &captureWriter{existing: os.DirFS(destPath), files: make(map[string][]byte)}
+	if _, err := runCopy(source, capture, opts, matcher, newRenderer(source, opts)); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(capture.files))
+	for p := range capture.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	diffs := make([]FileDiff, 0, len(paths))
+	for _, p := range paths {
+		rendered := capture.files[p]
+		existing, err := fs.ReadFile(capture.existing, p)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			diffs = append(diffs, FileDiff{Path: p, Status: DiffNew})
+		case err != nil:
+			return nil, fmt.Errorf("renderfs: read destination %s: %w", p, err)
+		case bytes.Equal(existing, rendered):
+			diffs = append(diffs, FileDiff{Path: p, Status: DiffUnchanged})
+		default:
+			diffs = append(diffs, FileDiff{
+				Path:   p,
+				Status: DiffModified,
+				Diff:   unifiedDiff(p, string(existing), string(rendered)),
+			})
+		}
+	}
+	return diffs, nil
+}
+
+// captureWriter records rendered files in memory while answering Lstat and
+// ReadFile from the existing destination tree, or as empty when existing is
+// nil.
+type captureWriter struct {
+	existing fs.FS
+	files    map[string][]byte
+	modes    map[string]fs.FileMode
+}
+
+func (w *captureWriter) MkdirAll(string, fs.FileMode) error { return nil }
+
+func (w *captureWriter) Symlink(string, string) error { return nil }
+
+func (w *captureWriter) Remove(string) error { return nil }
+
+func (w *captureWriter) CreateFile(p string, mode fs.FileMode) (io.WriteCloser, error) {
+	if w.modes != nil {
+		w.modes[p] = mode
+	}
+	return &captureFile{writer: w, path: p}, nil
+}
+
+func (w *captureWriter) Lstat(p string) (fs.FileInfo, error) {
+	if w.existing == nil {
+		return nil, fs.ErrNotExist
+	}
+	return fs.Lstat(w.existing, p)
+}
+
+func (w *captureWriter) ReadFile(p string) ([]byte, error) {
+	if w.existing == nil {
+		return nil, fs.ErrNotExist
+	}
+	return fs.ReadFile(w.existing, p)
+}
+
+type captureFile struct {
+	writer *captureWriter
+	path   string
+	buf    bytes.Buffer
+}
+
+func (f *captureFile) Write(p []byte) (int, error) { return f.buf.Write(p) }
+
+func (f *captureFile) Close() error {
+	f.writer.files[f.path] = f.buf.Bytes()
+	return nil
+}
+
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-', or '+'
+	line string
+}
+
+// unifiedDiff renders a unified diff of two texts with three lines of context.
+func unifiedDiff(name, before, after string) string {
+	ops := diffLines(splitLines(before), splitLines(after))
+
+	// oldPos and newPos hold the number of lines consumed before each op.
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if op.kind != '+' {
+			oldPos[i+1]++
+		}
+		if op.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		start := max(i-diffContext, 0)
+		last := i
+		for j := i; j < len(ops) && j-last <= 2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+		}
+		stop := min(last+diffContext+1, len(ops))
+
+		oldCount := oldPos[stop] - oldPos[start]
+		newCount := newPos[stop] - newPos[start]
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldPos[start], oldCount), hunkRange(newPos[start], newCount))
+		for _, op := range ops[start:stop] {
+			b.WriteByte(op.kind)
+			b.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = stop
+	}
+	return b.String()
+}
+
+func hunkRange(pos, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", pos+1)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, count)
+}
+
+// splitLines splits s after each newline, keeping the terminators so a
+// missing final newline shows up as a difference.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a line edit script using a longest common subsequence
+// over the lines between the common prefix and suffix.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lcs[i][j] is the LCS length of midA[i:] and midB[j:].
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			ops = append(ops, diffOp{' ', midA[i]})
+			i++
+			j++
+		case j < len(midB) && (i == len(midA) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', midB[j]})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', midA[i]})
+			i++
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
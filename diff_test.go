@@ -0,0 +1,63 @@
+package renderfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/your-org/renderfs"
+)
+
+func TestCopyDiffClassifiesFiles(t *testing.T) {
+	source := fstest.MapFS{
+		"config.yaml.tmpl": {
+			Data: []byte("name: {{ name }}\nport: 8080\nregion: eu\nreplicas: 2\n"),
+		},
+		"README.md": {
+			Data: []byte("# {{ name }}\n"),
+		},
+		"docs/NEW.md": {
+			Data: []byte("fresh\n"),
+		},
+	}
+
+	dest := t.TempDir()
+	writeFile := func(rel, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dest, rel), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	writeFile("config.yaml", "name: demo\nport: 80\nregion: eu\nreplicas: 2\n")
+	writeFile("README.md", "# demo\n")
+
+	diffs, err := renderfs.CopyDiff(source, dest, renderfs.Options{Context: pongo2.Context{"name": "demo"}})
+	if err != nil {
+		t.Fatalf("CopyDiff failed: %v", err)
+	}
+
+	want := []renderfs.FileDiff{
+		{Path: "README.md", Status: renderfs.DiffUnchanged},
+		{
+			Path:   "config.yaml",
+			Status: renderfs.DiffModified,
+			Diff: "--- a/config.yaml\n+++ b/config.yaml\n" +
+				"@@ -1,4 +1,4 @@\n name: demo\n-port: 80\n+port: 8080\n region: eu\n replicas: 2\n",
+		},
+		{Path: "docs/NEW.md", Status: renderfs.DiffNew},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("expected %d diffs, got %+v", len(want), diffs)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("diff %d:\ngot  %+v\nwant %+v", i, diffs[i], want[i])
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "docs")); !os.IsNotExist(err) {
+		t.Fatalf("expected CopyDiff not to write, stat returned %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package renderfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// dataFileName names the per-directory data file whose values apply to
+// everything rendered within that directory and below.
+const dataFileName = ".renderfs-data.yaml"
+
+// conditionFileName names the per-directory template that decides, with
+// Options.DirectoryConditions, whether the directory's contents are copied.
+const conditionFileName = ".renderfs-if"
+
+// dirContext returns base layered under the data files found in dir and its
+// ancestors, deeper files taking precedence, or base itself unless
+// Options.DirectoryData is set. Results are cached per
+// directory, so each data file is read at most once per cache.
+func (c *copier) dirContext(dir string, base pongo2.Context, cache map[string]pongo2.Context) (pongo2.Context, error) {
+	if !c.opts.DirectoryData {
+		return base, nil
+	}
+	if ctx, ok := cache[dir]; ok {
+		return ctx, nil
+	}
+
+	parent := base
+	if dir != "." {
+		var err error
+		if parent, err = c.dirContext(path.Dir(dir), base, cache); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := parent
+	values, err := readDataFile(c.source, path.Join(dir, dataFileName))
+	if err != nil {
+		return nil, err
+	}
+	if values != nil {
+		ctx = layerContexts(values, parent)
+	}
+	cache[dir] = ctx
+	return ctx, nil
+}
+
+// readDataFile parses the YAML mapping at name, returning nil when the file
+// does not exist.
+func readDataFile(source fs.FS, name string) (pongo2.Context, error) {
+	content, err := fs.ReadFile(source, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("renderfs: read %s: %w", name, err)
+	}
+
+	values := make(pongo2.Context)
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("renderfs: parse %s: %w", name, err)
+	}
+	return values, nil
+}
+
+// childrenSkipped reports whether the condition file in dir rendered blank,
+// so the entries below dir are left out. The condition is rendered with the
+// directory's content context once per copy.
+func (c *copier) childrenSkipped(dir string) (bool, error) {
+	if !c.opts.DirectoryConditions {
+		return false, nil
+	}
+	if skip, ok := c.conditions[dir]; ok {
+		return skip, nil
+	}
+
+	name := path.Join(dir, conditionFileName)
+	content, err := fs.ReadFile(c.source, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.conditions[dir] = false
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("renderfs: read %s: %w", name, err)
+	}
+
+	ctx, err := c.dirContext(dir, c.context, c.contentData)
+	if err != nil {
+		return false, err
+	}
+	rendered, err := c.renderer.renderTemplateString(string(content), ctx)
+	if err != nil {
+		return false, templateError(err, "render", name, 0)
+	}
+	skip := strings.TrimSpace(rendered) == ""
+	c.conditions[dir] = skip
+	return skip, nil
+}
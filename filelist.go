@@ -0,0 +1,56 @@
+package renderfs
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// fileListKey names the context variable that holds the destination file
+// paths when Options.ExposeFileList is set.
+const fileListKey = "__files__"
+
+// listFiles walks the source resolving destination paths only, and returns
+// the sorted paths of every file and symlink the copy would produce.
+func (c *copier) listFiles() ([]string, error) {
+	c.listOnly = true
+	defer func() {
+		c.listOnly = false
+		c.listed = nil
+		c.listedLinks = 0
+	}()
+
+	if err := fs.WalkDir(c.source, ".", c.visit); err != nil {
+		return nil, err
+	}
+	files := append([]string{}, c.listed...)
+	sort.Strings(files)
+	return files, nil
+}
+
+// CountFiles returns the number of files Copy would write from source under
+// opts, the number of Options.OnFile calls a copy makes, so a caller can show
+// progress against a total. Only paths are rendered: no file content is read,
+// so files that SkipUnchanged, SkipEmptyFiles, or OnceGlobs would drop are
+// still counted. Symlinks copied as links are not counted.
+func CountFiles(source fs.FS, opts Options) (int, error) {
+	if source == nil {
+		return 0, fmt.Errorf("renderfs: source filesystem is required")
+	}
+	matcher, err := buildIgnoreMatcher(source, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	// Nothing is written, so the prior manifest is left for the real copy.
+	opts.PriorManifest = nil
+	c, err := newCopier(source, &captureWriter{}, opts, matcher, newRenderer(source, opts))
+	if err != nil {
+		return 0, err
+	}
+	c.listOnly = true
+	if err := fs.WalkDir(source, ".", c.visit); err != nil {
+		return 0, err
+	}
+	return len(c.listed) - c.listedLinks, nil
+}
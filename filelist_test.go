@@ -0,0 +1,48 @@
+package renderfs_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/your-org/renderfs"
+	"github.com/your-org/renderfs/writers"
+)
+
+func TestCountFiles(t *testing.T) {
+	source := fstest.MapFS{
+		".renderfs-ignore":                      {Data: []byte("*.log\n")},
+		"README.md.tmpl":                        {Data: []byte("# {{ name }}\n")},
+		"src/{{ name }}/main.go":                {Data: []byte("package {{ name }}\n")},
+		"src/{{ name }}/.gitkeep":               {},
+		"{% if docs %}docs{% endif %}/guide.md": {Data: []byte("guide\n")},
+		"debug.log":                             {Data: []byte("noise\n")},
+		"link":                                  {Data: []byte("README.md"), Mode: fs.ModeSymlink | 0o777},
+	}
+	opts := renderfs.Options{
+		Context:        pongo2.Context{"name": "demo", "docs": false},
+		DropKeepMarker: true,
+	}
+
+	count, err := renderfs.CountFiles(source, opts)
+	if err != nil {
+		t.Fatalf("CountFiles failed: %v", err)
+	}
+
+	var events int
+	opts.OnFile = func(renderfs.FileEvent) error {
+		events++
+		return nil
+	}
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if written := len(writer.Contents()); count != written || count != events {
+		t.Fatalf("expected count %d to match %d written files and %d events", count, written, events)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 files, got %d", count)
+	}
+}
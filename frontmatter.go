@@ -0,0 +1,83 @@
+package renderfs
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+
+	"github.com/flosch/pongo2/v6"
+	"gopkg.in/yaml.v3"
+)
+
+var frontMatterFence = []byte("---")
+
+// splitFrontMatter separates a leading `---` fenced YAML block from the rest
+// of the content. ok is false when the content carries no front-matter.
+func splitFrontMatter(content []byte) (values map[string]interface{}, body []byte, ok bool, err error) {
+	first, rest, found := cutLine(content)
+	if !found || !bytes.Equal(first, frontMatterFence) {
+		return nil, content, false, nil
+	}
+
+	var block []byte
+	for len(rest) > 0 {
+		line, remaining, _ := cutLine(rest)
+		if bytes.Equal(line, frontMatterFence) {
+			values = make(map[string]interface{})
+			if err := yaml.Unmarshal(block, &values); err != nil {
+				return nil, nil, false, fmt.Errorf("renderfs: parse front-matter: %w", err)
+			}
+			return values, remaining, true, nil
+		}
+		block = append(block, rest[:len(rest)-len(remaining)]...)
+		rest = remaining
+	}
+
+	return nil, nil, false, fmt.Errorf("renderfs: unterminated front-matter")
+}
+
+// modeDirectiveRegex matches a first line such as {# renderfs:mode=0755 #}.
+var modeDirectiveRegex = regexp.MustCompile(`^\{#-?\s*renderfs:mode=(\S*?)\s*-?#\}$`)
+
+// splitModeDirective separates a leading mode directive line from the rest
+// of the content. ok is false when the content does not start with one.
+func splitModeDirective(content []byte) (mode fs.FileMode, body []byte, ok bool, err error) {
+	first, rest, _ := cutLine(content)
+	match := modeDirectiveRegex.FindSubmatch(bytes.TrimSpace(first))
+	if match == nil {
+		return 0, content, false, nil
+	}
+	perm, err := strconv.ParseUint(string(match[1]), 8, 32)
+	if err != nil || perm > 0o777 {
+		return 0, nil, false, fmt.Errorf("renderfs: invalid mode directive %q", match[1])
+	}
+	return fs.FileMode(perm), rest, true, nil
+}
+
+// strippedLines counts the lines removed from the start of content to leave
+// body, a suffix of it, such as a mode directive or front-matter block.
+func strippedLines(content, body []byte) int {
+	return bytes.Count(content[:len(content)-len(body)], []byte("\n"))
+}
+
+// cutLine splits off the first line, dropping its line terminator. found
+// reports whether a line terminator was present.
+func cutLine(content []byte) (line, rest []byte, found bool) {
+	line, rest, found = bytes.Cut(content, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r")), rest, found
+}
+
+// withOverrides returns a shallow copy of ctx with the given values applied on
+// top. The base context is left untouched.
+func withOverrides(ctx pongo2.Context, values map[string]interface{}) pongo2.Context {
+	merged := make(pongo2.Context, len(ctx)+len(values))
+	for k, v := range ctx {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,39 @@
+package renderfs
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// matchGlob reports whether rel matches pattern using path.Match semantics.
+// Patterns without a slash are matched against the base name as well, so
+// "*.json" applies at any depth.
+func matchGlob(pattern, rel string) bool {
+	if ok, _ := path.Match(pattern, rel); ok {
+		return true
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return false
+}
+
+// lookupGlob returns the value of the first pattern in m (in sorted order)
+// matching rel.
+func lookupGlob[V any](m map[string]V, rel string) (V, bool) {
+	patterns := make([]string, 0, len(m))
+	for pattern := range m {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return m[pattern], true
+		}
+	}
+	var zero V
+	return zero, false
+}
@@ -3,6 +3,7 @@ module github.com/your-org/renderfs
 go 1.25.3
 
 require (
-	github.com/flosch/pongo2/v6 v6.0.0 // indirect
-	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06 // indirect
+	github.com/flosch/pongo2/v6 v6.0.0
+	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -10,23 +10,56 @@ import (
 	ignore "github.com/sabhiram/go-gitignore"
 )
 
-func buildIgnoreMatcher(source fs.FS, patterns []string) (*ignore.GitIgnore, error) {
-	lines := make([]string, 0, len(patterns))
-	for _, pattern := range patterns {
-		pattern = strings.TrimSpace(pattern)
-		if pattern == "" {
-			continue
+// IgnoreMatcher holds compiled ignore rules so repeated copies from the same
+// source can skip reading and compiling them. It is safe for concurrent use.
+type IgnoreMatcher struct {
+	matcher *ignore.GitIgnore
+}
+
+// CompileIgnore builds the ignore rules Copy would use for source and opts:
+// IgnorePatterns, or otherwise the source's .renderfs-ignore (and .gitignore
+// when UseGitignore is set). Pass the result as Options.Ignore.
+func CompileIgnore(source fs.FS, opts Options) (*IgnoreMatcher, error) {
+	if source == nil {
+		return nil, fmt.Errorf("renderfs: source filesystem is required")
+	}
+	opts.Ignore = nil
+	matcher, err := buildIgnoreMatcher(source, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &IgnoreMatcher{matcher: matcher}, nil
+}
+
+func buildIgnoreMatcher(source fs.FS, opts Options) (*ignore.GitIgnore, error) {
+	if opts.Ignore != nil {
+		return opts.Ignore.matcher, nil
+	}
+
+	lines := cleanPatterns(opts.IgnorePatterns)
+	if opts.RenderIgnorePatterns && len(lines) > 0 {
+		var err error
+		if lines, err = renderPatterns(source, opts, lines); err != nil {
+			return nil, err
 		}
-		lines = append(lines, pattern)
 	}
 
 	if len(lines) == 0 {
-		raw, err := fs.ReadFile(source, ".renderfs-ignore")
-		if err != nil && !errors.Is(err, fs.ErrNotExist) {
-			return nil, fmt.Errorf("renderfs: read .renderfs-ignore: %w", err)
+		fromFile, err := readIgnoreFile(source, ".renderfs-ignore")
+		if err != nil {
+			return nil, err
 		}
-		if len(raw) > 0 {
-			lines = append(lines, parseIgnoreFile(string(raw))...)
+		lines = append(lines, fromFile...)
+
+		if opts.UseGitignore {
+			fromGit, err := readIgnoreFile(source, ".gitignore")
+			if err != nil {
+				return nil, err
+			}
+			if len(fromGit) > 0 {
+				lines = append(lines, fromGit...)
+				lines = append(lines, "/.gitignore")
+			}
 		}
 	}
 
@@ -34,10 +67,83 @@ func buildIgnoreMatcher(source fs.FS, patterns []string) (*ignore.GitIgnore, err
 		return nil, nil
 	}
 
-	lines = append(lines, ".renderfs-ignore")
+	if !opts.IncludeIgnoreFile {
+		lines = append(lines, ".renderfs-ignore")
+	}
 	return ignore.CompileIgnoreLines(lines...), nil
 }
 
+// renderPatterns renders each pattern as a template against the copy
+// context. Patterns that render empty are dropped.
+func renderPatterns(source fs.FS, opts Options, patterns []string) ([]string, error) {
+	r := newRenderer(source, opts)
+	context := effectiveContext(opts)
+
+	rendered := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		out, err := r.renderTemplateString(pattern, context)
+		if err != nil {
+			return nil, fmt.Errorf("renderfs: render ignore pattern %q: %w", pattern, err)
+		}
+		rendered = append(rendered, out)
+	}
+	return cleanPatterns(rendered), nil
+}
+
+// readIgnoreFile returns the patterns in the named file at the source root, or
+// nothing when the file does not exist.
+func readIgnoreFile(source fs.FS, name string) ([]string, error) {
+	raw, err := fs.ReadFile(source, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("renderfs: read %s: %w", name, err)
+	}
+	return parseIgnoreFile(string(raw)), nil
+}
+
+// compilePatterns compiles gitignore-style patterns, returning nil when there
+// is nothing to match.
+func compilePatterns(patterns []string) *ignore.GitIgnore {
+	lines := cleanPatterns(patterns)
+	if len(lines) == 0 {
+		return nil
+	}
+	return ignore.CompileIgnoreLines(lines...)
+}
+
+// matchesEntry matches rel against m, also trying the trailing-slash form for
+// directories so patterns such as "build/" exclude the directory itself.
+func matchesEntry(m *ignore.GitIgnore, rel string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	return m.MatchesPath(rel) || (isDir && m.MatchesPath(rel+"/"))
+}
+
+func cleanPatterns(patterns []string) []string {
+	lines := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		lines = append(lines, pattern)
+	}
+	return lines
+}
+
+// isIgnored reports whether rel is excluded from rendering, either by the
+// compiled patterns or because it is the ignore file itself and
+// includeIgnoreFile is unset.
+func isIgnored(matcher *ignore.GitIgnore, rel string, isDir, includeIgnoreFile bool) bool {
+	if matchesEntry(matcher, rel, isDir) {
+		return true
+	}
+	return !includeIgnoreFile && rel == ".renderfs-ignore"
+}
+
 func parseIgnoreFile(content string) []string {
 	var patterns []string
 	scanner := bufio.NewScanner(strings.NewReader(content))
@@ -0,0 +1,109 @@
+package renderfs_test
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/your-org/renderfs"
+	"github.com/your-org/renderfs/writers"
+)
+
+func TestCompileIgnoreReusedAcrossCopies(t *testing.T) {
+	source := fstest.MapFS{
+		".renderfs-ignore": {Data: []byte("*.log\n")},
+		"app.txt":          {Data: []byte("app")},
+		"debug.log":        {Data: []byte("noise")},
+	}
+
+	matcher, err := renderfs.CompileIgnore(source, renderfs.Options{})
+	if err != nil {
+		t.Fatalf("CompileIgnore failed: %v", err)
+	}
+
+	// The precompiled rules win even though the file has since changed.
+	source[".renderfs-ignore"] = &fstest.MapFile{Data: []byte("app.txt\n")}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{Ignore: matcher}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if _, ok := writer.Contents()["debug.log"]; ok {
+		t.Fatalf("expected debug.log to be ignored")
+	}
+	if _, ok := writer.Contents()["app.txt"]; !ok {
+		t.Fatalf("expected app.txt to be copied")
+	}
+	if _, ok := writer.Contents()[".renderfs-ignore"]; ok {
+		t.Fatalf("expected .renderfs-ignore to be left out")
+	}
+}
+
+func ignoreBenchmarkSource() (fstest.MapFS, []string) {
+	source := fstest.MapFS{}
+	var patterns []string
+	for i := 0; i < 200; i++ {
+		patterns = append(patterns, fmt.Sprintf("build%d/**", i), fmt.Sprintf("*.tmp%d", i))
+	}
+	for i := 0; i < 10; i++ {
+		source[fmt.Sprintf("file%d.txt", i)] = &fstest.MapFile{Data: []byte("x")}
+	}
+	return source, patterns
+}
+
+func BenchmarkCopyIgnorePatterns(b *testing.B) {
+	source, patterns := ignoreBenchmarkSource()
+	opts := renderfs.Options{IgnorePatterns: patterns}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCopyPrecompiledIgnore(b *testing.B) {
+	source, patterns := ignoreBenchmarkSource()
+	matcher, err := renderfs.CompileIgnore(source, renderfs.Options{IgnorePatterns: patterns})
+	if err != nil {
+		b.Fatal(err)
+	}
+	opts := renderfs.Options{Ignore: matcher}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCopyRenderIgnorePatterns(t *testing.T) {
+	source := fstest.MapFS{
+		"dist/app.js": {Data: []byte("built")},
+		"src/app.js":  {Data: []byte("source")},
+	}
+	opts := renderfs.Options{
+		Context:              pongo2.Context{"build_dir": "dist"},
+		IgnorePatterns:       []string{"{{ build_dir }}/"},
+		RenderIgnorePatterns: true,
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if _, ok := writer.Contents()["dist/app.js"]; ok {
+		t.Fatalf("expected rendered pattern to exclude dist/")
+	}
+	if _, ok := writer.Contents()["src/app.js"]; !ok {
+		t.Fatalf("expected src/app.js to be copied")
+	}
+
+	opts.Context = nil
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err == nil {
+		t.Fatalf("expected a pattern referencing a missing value to fail")
+	}
+}
@@ -0,0 +1,147 @@
+package renderfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+var includeTagRegex = regexp.MustCompile(`{%-?\s*(?:include|extends|import)\s+("[^"]*"|'[^']*')`)
+
+// referencesTemplates reports whether tpl loads other templates through
+// include, extends, or import tags.
+func referencesTemplates(tpl string) bool {
+	return strings.Contains(tpl, "{%") && includeTagRegex.MatchString(tpl)
+}
+
+// referencedTemplates returns the literal template names loaded by tpl.
+// Names computed from variables cannot be known statically and are omitted.
+func referencedTemplates(tpl string) []string {
+	var names []string
+	for _, match := range includeTagRegex.FindAllStringSubmatch(tpl, -1) {
+		names = append(names, match[1][1:len(match[1])-1])
+	}
+	return names
+}
+
+// newTemplateSet returns a pongo2 template set that resolves include, extends,
+// and import tags against the source filesystem.
+func newTemplateSet(source fs.FS) *pongo2.TemplateSet {
+	return pongo2.NewSet("renderfs", pongo2.NewFSLoader(source))
+}
+
+// fallbackFS serves files from primary, or from fallback when primary does
+// not have them.
+type fallbackFS struct {
+	primary  fs.FS
+	fallback fs.FS
+}
+
+func (f fallbackFS) Open(name string) (fs.File, error) {
+	file, err := f.primary.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f.fallback.Open(name)
+	}
+	return file, err
+}
+
+// checkIncludeCycles follows the templates loaded by tpl through the source
+// filesystem and reports the first cycle it finds. pongo2 resolves includes
+// while parsing, so a cycle would otherwise recurse until the stack overflows.
+// Names in the top-level template resolve from the source root; names inside
+// a source file resolve relative to that file's directory, as pongo2 does.
+func checkIncludeCycles(source fs.FS, tpl string) error {
+	var stack []string
+	onStack := make(map[string]bool)
+	done := make(map[string]bool)
+
+	var visit func(name string, names []string) error
+	visit = func(name string, names []string) error {
+		stack = append(stack, name)
+		onStack[name] = true
+		defer func() {
+			stack = stack[:len(stack)-1]
+			delete(onStack, name)
+			done[name] = true
+		}()
+
+		for _, ref := range names {
+			next := path.Clean(ref)
+			if name != "" {
+				next = path.Join(path.Dir(name), ref)
+			}
+
+			if onStack[next] {
+				cycle := append(append([]string(nil), stack[1:]...), next)
+				start := 0
+				for i, n := range cycle {
+					if n == next {
+						start = i
+						break
+					}
+				}
+				return fmt.Errorf("renderfs: include cycle: %s", strings.Join(cycle[start:], " -> "))
+			}
+			if done[next] {
+				continue
+			}
+
+			content, err := fs.ReadFile(source, next)
+			if err != nil {
+				// Missing templates are reported by pongo2 when compiling.
+				done[next] = true
+				continue
+			}
+			if err := visit(next, referencedTemplates(string(content))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return visit("", referencedTemplates(tpl))
+}
+
+// includedTemplate is a template loaded by another through include, extends,
+// or import.
+type includedTemplate struct {
+	name    string
+	content string
+}
+
+// includedTemplates returns every template tpl loads, directly or through
+// other templates, resolving names as checkIncludeCycles does. Each template
+// is returned once; missing templates are left for pongo2 to report.
+func includedTemplates(source fs.FS, tpl string) []includedTemplate {
+	var result []includedTemplate
+	seen := make(map[string]bool)
+
+	var visit func(name string, names []string)
+	visit = func(name string, names []string) {
+		for _, ref := range names {
+			next := path.Clean(ref)
+			if name != "" {
+				next = path.Join(path.Dir(name), ref)
+			}
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+
+			content, err := fs.ReadFile(source, next)
+			if err != nil {
+				continue
+			}
+			result = append(result, includedTemplate{name: next, content: string(content)})
+			visit(next, referencedTemplates(string(content)))
+		}
+	}
+
+	visit("", referencedTemplates(tpl))
+	return result
+}
@@ -0,0 +1,255 @@
+// Package memfs provides a read-only, in-memory fs.FS with symlink support,
+// shared by the writers' output snapshots and renderfs source snapshots.
+package memfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSymlinkHops bounds symlink resolution.
+const maxSymlinkHops = 40
+
+// FS is an in-memory filesystem built with AddDir, AddFile, and AddSymlink.
+// Directories implied by added paths are created with mode 0o755.
+//
+// Open, Stat, and ReadFile follow symlinks whose targets resolve within the
+// filesystem; a link whose target is missing, absolute, or outside the root
+// reports fs.ErrNotExist when opened. FS also implements fs.ReadLinkFS, whose
+// Lstat and ReadLink describe links themselves, and ReadDir lists links as
+// entries of type fs.ModeSymlink.
+//
+// An FS must not be modified once it is in use; reads are then safe for
+// concurrent use.
+type FS struct {
+	entries  map[string]entry
+	children map[string][]string
+}
+
+type entry struct {
+	mode   fs.FileMode
+	data   []byte
+	target string
+}
+
+// New returns an FS holding only its root directory.
+func New() *FS {
+	return &FS{
+		entries:  map[string]entry{".": {mode: fs.ModeDir | 0o755}},
+		children: make(map[string][]string),
+	}
+}
+
+// AddDir records a directory at p with the given permissions.
+func (m *FS) AddDir(p string, perm fs.FileMode) {
+	m.add(p, entry{mode: fs.ModeDir | perm.Perm()})
+}
+
+// AddFile records a regular file at p. data is retained, not copied.
+func (m *FS) AddFile(p string, perm fs.FileMode, data []byte) {
+	m.add(p, entry{mode: perm.Perm(), data: data})
+}
+
+// AddSymlink records a symlink at p pointing to target.
+func (m *FS) AddSymlink(p, target string) {
+	m.add(p, entry{mode: fs.ModeSymlink | 0o777, target: target})
+}
+
+// add records e at p, creating any missing ancestor directories and keeping
+// each directory's children sorted.
+func (m *FS) add(p string, e entry) {
+	if _, ok := m.entries[p]; !ok {
+		dir := path.Dir(p)
+		if _, ok := m.entries[dir]; !ok {
+			m.add(dir, entry{mode: fs.ModeDir | 0o755})
+		}
+		names := m.children[dir]
+		name := path.Base(p)
+		i := sort.SearchStrings(names, name)
+		m.children[dir] = append(names[:i], append([]string{name}, names[i:]...)...)
+	}
+	m.entries[p] = e
+}
+
+// resolve looks up name, following symlinks along the way. The final
+// element is followed only when follow is set.
+func (m *FS) resolve(op, name string, follow bool) (string, entry, error) {
+	if !fs.ValidPath(name) {
+		return "", entry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	resolved := "."
+	pending := strings.Split(name, "/")
+	for hops := 0; len(pending) > 0; {
+		next := path.Join(resolved, pending[0])
+		pending = pending[1:]
+		if next == resolved {
+			continue
+		}
+
+		e, ok := m.entries[next]
+		if !ok {
+			return "", entry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if e.mode&fs.ModeSymlink != 0 && (len(pending) > 0 || follow) {
+			if hops++; hops > maxSymlinkHops {
+				return "", entry{}, &fs.PathError{Op: op, Path: name, Err: errors.New("too many levels of symbolic links")}
+			}
+			target := path.Join(path.Dir(next), e.target)
+			if path.IsAbs(e.target) || target == ".." || strings.HasPrefix(target, "../") {
+				return "", entry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+			}
+			resolved = "."
+			pending = append(strings.Split(target, "/"), pending...)
+			continue
+		}
+		if !e.mode.IsDir() && len(pending) > 0 {
+			return "", entry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		resolved = next
+	}
+	return resolved, m.entries[resolved], nil
+}
+
+func (m *FS) Open(name string) (fs.File, error) {
+	resolved, e, err := m.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := entryInfo{name: path.Base(name), entry: e}
+	if e.mode.IsDir() {
+		return &dirHandle{fsys: m, dir: resolved, info: info}, nil
+	}
+	return &fileHandle{Reader: bytes.NewReader(e.data), info: info}, nil
+}
+
+func (m *FS) Stat(name string) (fs.FileInfo, error) {
+	_, e, err := m.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return entryInfo{name: path.Base(name), entry: e}, nil
+}
+
+func (m *FS) ReadFile(name string) ([]byte, error) {
+	_, e, err := m.resolve("readfile", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if e.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+	return append([]byte(nil), e.data...), nil
+}
+
+func (m *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	resolved, e, err := m.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !e.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return m.dirEntries(resolved), nil
+}
+
+func (m *FS) Lstat(name string) (fs.FileInfo, error) {
+	_, e, err := m.resolve("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return entryInfo{name: path.Base(name), entry: e}, nil
+}
+
+func (m *FS) ReadLink(name string) (string, error) {
+	_, e, err := m.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if e.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return e.target, nil
+}
+
+func (m *FS) dirEntries(dir string) []fs.DirEntry {
+	names := m.children[dir]
+	entries := make([]fs.DirEntry, len(names))
+	for i, child := range names {
+		info := entryInfo{name: child, entry: m.entries[path.Join(dir, child)]}
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries
+}
+
+type entryInfo struct {
+	name  string
+	entry entry
+}
+
+func (i entryInfo) Name() string { return i.name }
+func (i entryInfo) Size() int64 {
+	if i.entry.mode&fs.ModeSymlink != 0 {
+		return int64(len(i.entry.target))
+	}
+	return int64(len(i.entry.data))
+}
+func (i entryInfo) Mode() fs.FileMode  { return i.entry.mode }
+func (i entryInfo) ModTime() time.Time { return time.Unix(0, 0) }
+func (i entryInfo) IsDir() bool        { return i.entry.mode.IsDir() }
+func (i entryInfo) Sys() interface{}   { return nil }
+
+type fileHandle struct {
+	*bytes.Reader
+	info entryInfo
+}
+
+func (f *fileHandle) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *fileHandle) Close() error               { return nil }
+
+type dirHandle struct {
+	fsys    *FS
+	dir     string
+	info    entryInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirHandle) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dirHandle) Close() error               { return nil }
+
+func (d *dirHandle) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *dirHandle) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		d.entries = d.fsys.dirEntries(d.dir)
+	}
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
+
+var (
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadLinkFS = (*FS)(nil)
+)
@@ -0,0 +1,67 @@
+package renderfs
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadContext reads YAML or JSON mapping files from source and merges them
+// into one context. Later paths take precedence, with nested maps merged key
+// by key as in Options.ContextChain.
+func LoadContext(source fs.FS, paths ...string) (pongo2.Context, error) {
+	layers := make([]pongo2.Context, 0, len(paths))
+	for i := len(paths) - 1; i >= 0; i-- {
+		value, err := loadDataFile(source, paths[i])
+		if err != nil {
+			return nil, err
+		}
+		values, ok := value.(map[string]interface{})
+		if !ok && value != nil {
+			return nil, fmt.Errorf("renderfs: %s does not contain a mapping", paths[i])
+		}
+		layers = append(layers, values)
+	}
+	return layerContexts(layers...), nil
+}
+
+// LoadContextNamespaced reads the data file mapped to each namespace and
+// places its content under that key, so {"db": "db.yaml"} exposes the
+// file's host value as db.host. Files may hold any YAML or JSON document,
+// and keys from different files cannot collide.
+func LoadContextNamespaced(source fs.FS, mapping map[string]string) (pongo2.Context, error) {
+	namespaces := make([]string, 0, len(mapping))
+	for ns := range mapping {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	ctx := make(pongo2.Context, len(mapping))
+	for _, ns := range namespaces {
+		if strings.Contains(ns, ".") || !identifierPathRegex.MatchString(ns) {
+			return nil, fmt.Errorf("renderfs: invalid namespace %q", ns)
+		}
+		value, err := loadDataFile(source, mapping[ns])
+		if err != nil {
+			return nil, err
+		}
+		ctx[ns] = value
+	}
+	return ctx, nil
+}
+
+func loadDataFile(source fs.FS, name string) (interface{}, error) {
+	content, err := fs.ReadFile(source, name)
+	if err != nil {
+		return nil, fmt.Errorf("renderfs: read %s: %w", name, err)
+	}
+	var value interface{}
+	if err := yaml.Unmarshal(content, &value); err != nil {
+		return nil, fmt.Errorf("renderfs: parse %s: %w", name, err)
+	}
+	return value, nil
+}
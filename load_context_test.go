@@ -0,0 +1,64 @@
+package renderfs_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/your-org/renderfs"
+	"github.com/your-org/renderfs/writers"
+)
+
+func TestLoadContext(t *testing.T) {
+	source := fstest.MapFS{
+		"base.yaml":     {Data: []byte("name: app\ndb:\n  host: localhost\n  port: 5432\n")},
+		"override.json": {Data: []byte(`{"db": {"host": "db.internal"}}`)},
+		"list.yaml":     {Data: []byte("- a\n- b\n")},
+	}
+
+	ctx, err := renderfs.LoadContext(source, "base.yaml", "override.json")
+	if err != nil {
+		t.Fatalf("LoadContext failed: %v", err)
+	}
+	db := ctx["db"].(map[string]interface{})
+	if ctx["name"] != "app" || db["host"] != "db.internal" || db["port"] != 5432 {
+		t.Fatalf("unexpected context: %v", ctx)
+	}
+
+	if _, err := renderfs.LoadContext(source, "list.yaml"); err == nil {
+		t.Fatalf("expected non-mapping file to be rejected")
+	}
+	if _, err := renderfs.LoadContext(source, "missing.yaml"); err == nil {
+		t.Fatalf("expected missing file to fail")
+	}
+}
+
+func TestLoadContextNamespaced(t *testing.T) {
+	data := fstest.MapFS{
+		"db.yaml":    {Data: []byte("host: localhost\nname: app\n")},
+		"app.json":   {Data: []byte(`{"name": "demo"}`)},
+		"hosts.yaml": {Data: []byte("- web1\n- web2\n")},
+	}
+	ctx, err := renderfs.LoadContextNamespaced(data, map[string]string{
+		"db":    "db.yaml",
+		"app":   "app.json",
+		"hosts": "hosts.yaml",
+	})
+	if err != nil {
+		t.Fatalf("LoadContextNamespaced failed: %v", err)
+	}
+
+	source := fstest.MapFS{
+		"out.txt": {Data: []byte("{{ db.host }} {{ db.name }} {{ app.name }} {{ hosts[1] }}")},
+	}
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: ctx}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["out.txt"]); got != "localhost app demo web2" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	if _, err := renderfs.LoadContextNamespaced(data, map[string]string{"db.primary": "db.yaml"}); err == nil {
+		t.Fatalf("expected dotted namespace to be rejected")
+	}
+}
@@ -0,0 +1,55 @@
+package renderfs
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeManifest emits entries in sha256sum format ("<hex>  <path>"), sorted
+// by path so the output is stable across runs.
+func writeManifest(w io.Writer, entries map[string]string) error {
+	paths := make([]string, 0, len(entries))
+	for p := range entries {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		if _, err := fmt.Fprintf(w, "%s  %s\n", entries[p], p); err != nil {
+			return fmt.Errorf("renderfs: write manifest: %w", err)
+		}
+	}
+	return nil
+}
+
+// readManifest parses a listing produced by writeManifest (or sha256sum) into
+// a path to checksum map.
+func readManifest(r io.Reader) (map[string]string, error) {
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		sum, p, ok := strings.Cut(text, " ")
+		if !ok || len(sum) != sha256.Size*2 || len(p) < 2 || (p[0] != ' ' && p[0] != '*') {
+			return nil, fmt.Errorf("renderfs: prior manifest line %d is malformed", line)
+		}
+		entries[p[1:]] = strings.ToLower(sum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("renderfs: read prior manifest: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,43 @@
+package renderfs
+
+import "github.com/flosch/pongo2/v6"
+
+// NormalizeOptions returns opts with the defaults Copy applies filled in:
+//
+//   - Context is layered over ContextChain, which is cleared, and is never nil.
+//   - An unknown OnConflict becomes Overwrite.
+//   - An unknown LineEndings becomes PreserveLineEndings.
+//   - An empty KeepMarker becomes ".gitkeep".
+//   - A zero MaxNameLength becomes 255.
+//   - Negative RenderTimeout, MaxDepth, MaxRenderedSize, and
+//     MaxSourceFileSize become 0, which disables them.
+//
+// Now and RandSeed keep their zero values, which Copy resolves from the clock
+// when it starts. Copy normalizes its options the same way, so passing the
+// result to Copy behaves exactly like passing opts.
+func NormalizeOptions(opts Options) Options {
+	if len(opts.ContextChain) > 0 {
+		opts.Context = layerContexts(append([]pongo2.Context{opts.Context}, opts.ContextChain...)...)
+		opts.ContextChain = nil
+	}
+	if opts.Context == nil {
+		opts.Context = pongo2.Context{}
+	}
+	if opts.OnConflict < Overwrite || opts.OnConflict > Fail {
+		opts.OnConflict = Overwrite
+	}
+	if opts.LineEndings < PreserveLineEndings || opts.LineEndings > CRLF {
+		opts.LineEndings = PreserveLineEndings
+	}
+	if opts.KeepMarker == "" {
+		opts.KeepMarker = ".gitkeep"
+	}
+	if opts.MaxNameLength == 0 {
+		opts.MaxNameLength = 255
+	}
+	opts.RenderTimeout = max(opts.RenderTimeout, 0)
+	opts.MaxDepth = max(opts.MaxDepth, 0)
+	opts.MaxRenderedSize = max(opts.MaxRenderedSize, 0)
+	opts.MaxSourceFileSize = max(opts.MaxSourceFileSize, 0)
+	return opts
+}
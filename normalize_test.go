@@ -0,0 +1,76 @@
+package renderfs_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/your-org/renderfs"
+)
+
+func TestNormalizeOptionsDefaults(t *testing.T) {
+	got := renderfs.NormalizeOptions(renderfs.Options{
+		OnConflict:        renderfs.ConflictResolution(42),
+		LineEndings:       renderfs.LineEnding(-1),
+		RenderTimeout:     -time.Second,
+		MaxDepth:          -1,
+		MaxRenderedSize:   -1,
+		MaxSourceFileSize: -1,
+	})
+
+	if got.Context == nil || len(got.Context) != 0 {
+		t.Fatalf("expected an empty context, got %v", got.Context)
+	}
+	if got.OnConflict != renderfs.Overwrite {
+		t.Fatalf("expected Overwrite, got %v", got.OnConflict)
+	}
+	if got.LineEndings != renderfs.PreserveLineEndings {
+		t.Fatalf("expected PreserveLineEndings, got %v", got.LineEndings)
+	}
+	if got.KeepMarker != ".gitkeep" {
+		t.Fatalf("expected .gitkeep keep marker, got %q", got.KeepMarker)
+	}
+	if got.MaxNameLength != 255 {
+		t.Fatalf("expected name length limit 255, got %d", got.MaxNameLength)
+	}
+	if got.RenderTimeout != 0 || got.MaxDepth != 0 || got.MaxRenderedSize != 0 || got.MaxSourceFileSize != 0 {
+		t.Fatalf("expected negative limits to be cleared, got %v %v %v %v",
+			got.RenderTimeout, got.MaxDepth, got.MaxRenderedSize, got.MaxSourceFileSize)
+	}
+}
+
+func TestNormalizeOptionsKeepsExplicitValues(t *testing.T) {
+	opts := renderfs.Options{
+		Context:       pongo2.Context{"name": "demo"},
+		OnConflict:    renderfs.Fail,
+		LineEndings:   renderfs.CRLF,
+		KeepMarker:    ".keep",
+		RenderTimeout: time.Second,
+		MaxDepth:      3,
+	}
+	got := renderfs.NormalizeOptions(opts)
+
+	if !reflect.DeepEqual(got.Context, opts.Context) || got.OnConflict != renderfs.Fail ||
+		got.LineEndings != renderfs.CRLF || got.KeepMarker != ".keep" ||
+		got.RenderTimeout != time.Second || got.MaxDepth != 3 {
+		t.Fatalf("expected explicit values to be kept, got %+v", got)
+	}
+}
+
+func TestNormalizeOptionsLayersContextChain(t *testing.T) {
+	got := renderfs.NormalizeOptions(renderfs.Options{
+		Context: pongo2.Context{"name": "override", "db": map[string]interface{}{"port": 5433}},
+		ContextChain: []pongo2.Context{
+			{"name": "base", "db": map[string]interface{}{"host": "localhost", "port": 5432}},
+		},
+	})
+
+	if got.ContextChain != nil {
+		t.Fatalf("expected ContextChain to be cleared, got %v", got.ContextChain)
+	}
+	want := pongo2.Context{"name": "override", "db": map[string]interface{}{"host": "localhost", "port": 5433}}
+	if !reflect.DeepEqual(got.Context, want) {
+		t.Fatalf("expected layered context %v, got %v", want, got.Context)
+	}
+}
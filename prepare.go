@@ -0,0 +1,179 @@
+package renderfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sync"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// Prepared is a template tree that has been read, filtered, and compiled once
+// so it can be rendered repeatedly with different contexts. A Prepared value
+// is safe for concurrent use.
+type Prepared struct {
+	source   fs.FS
+	opts     Options
+	renderer *renderer
+}
+
+// Prepare reads the source filesystem into memory, applies ignore patterns,
+// and compiles every path, symlink target, and file template. Compilation
+// errors are reported here rather than on first render. The returned value
+// renders with opts, except that Render supplies the context.
+func Prepare(source fs.FS, opts Options) (*Prepared, error) {
+	if source == nil {
+		return nil, fmt.Errorf("renderfs: source filesystem is required")
+	}
+
+	snapshot, err := Snapshot(source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newRenderer(snapshot, opts)
+	c, err := precompiler(snapshot, opts, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.precompileAll(); err != nil {
+		return nil, err
+	}
+
+	return &Prepared{source: snapshot, opts: opts, renderer: r}, nil
+}
+
+// precompiler returns a copier over source carrying only what precompileAll
+// needs, for compiling a tree without preparing a destination.
+func precompiler(source fs.FS, opts Options, r *renderer) (*copier, error) {
+	opts = NormalizeOptions(opts)
+	c := &copier{
+		source:          source,
+		opts:            opts,
+		renderer:        r,
+		verbatimMatcher: compilePatterns(opts.VerbatimPatterns),
+	}
+	if len(opts.Redirects) > 0 {
+		redirects, err := compileRedirects(opts.Redirects)
+		if err != nil {
+			return nil, err
+		}
+		c.redirects = redirects
+	}
+	return c, nil
+}
+
+// Render writes the prepared tree to dest using ctx as the template context.
+func (p *Prepared) Render(ctx pongo2.Context, dest Writer) error {
+	if dest == nil {
+		return fmt.Errorf("renderfs: destination writer is required")
+	}
+
+	opts := p.opts
+	opts.Context = ctx
+	_, err := stagedCopy(p.source, dest, opts, nil, p.renderer)
+	return err
+}
+
+// precompile compiles the templates of one source entry: its path template,
+// normally rel itself, and, when body is not nil, its content or symlink
+// target.
+func precompile(r *renderer, opts Options, rel, pathTemplate string, body []byte) error {
+	compile := func(tpl string) error {
+		cachedCandidates(tpl)
+		_, err := r.compile(tpl)
+		return err
+	}
+
+	if err := compile(pathTemplate); err != nil {
+		return templateError(err, "compile path", rel, 0)
+	}
+	if body == nil {
+		return nil
+	}
+	raw := body
+	if _, stripped, ok, err := splitModeDirective(body); err == nil && ok {
+		body = stripped
+	}
+	if opts.FrontMatter {
+		if _, stripped, ok, err := splitFrontMatter(body); err == nil && ok {
+			body = stripped
+		}
+	}
+	if err := compile(string(body)); err != nil {
+		return templateError(err, "compile", rel, strippedLines(raw, body))
+	}
+	return nil
+}
+
+// precompileAll compiles every template the copy renders before anything is
+// written, for Options.PrecompileTemplates.
+func (c *copier) precompileAll() error {
+	return fs.WalkDir(c.source, ".", func(rel string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if rel == "." {
+			return nil
+		}
+		if isIgnored(c.matcher, rel, d.IsDir(), c.opts.IncludeIgnoreFile) || c.verbatim(rel, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		var body []byte
+		switch {
+		case d.Type().IsRegular():
+			if c.opts.DirectoryData && path.Base(rel) == dataFileName {
+				return nil
+			}
+			content, err := c.readSource(rel)
+			if err != nil {
+				return err
+			}
+			body = append([]byte{}, content...)
+		case d.Type()&fs.ModeSymlink != 0 && c.opts.RenderSymlinkTargets:
+			target, err := readSymlink(c.source, rel)
+			if err != nil {
+				return fmt.Errorf("renderfs: read symlink %s: %w", rel, err)
+			}
+			body = []byte(target)
+		}
+		return precompile(c.renderer, c.opts, rel, c.pathTemplate(rel, d.IsDir()), body)
+	})
+}
+
+// Target pairs a template context with the Writer its output goes to.
+type Target struct {
+	Context pongo2.Context
+	Dest    Writer
+}
+
+// CopyMany renders the source tree once per target. Templates are prepared a
+// single time and the targets are rendered concurrently; errors from every
+// failing target are joined together.
+func CopyMany(source fs.FS, targets []Target, opts Options) error {
+	prepared, err := Prepare(source, opts)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := prepared.Render(target.Context, target.Dest); err != nil {
+				errs[i] = fmt.Errorf("renderfs: target %d: %w", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
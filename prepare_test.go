@@ -0,0 +1,215 @@
+package renderfs_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/your-org/renderfs"
+	"github.com/your-org/renderfs/writers"
+)
+
+func TestPreparedRenderReusesTemplates(t *testing.T) {
+	source := fstest.MapFS{
+		".renderfs-ignore": {
+			Data: []byte("*.bak\n"),
+		},
+		"{{ name }}.txt.jinja": {
+			Data: []byte("hello {{ name }}\n"),
+		},
+		"notes.bak": {
+			Data: []byte("ignored"),
+		},
+	}
+
+	prepared, err := renderfs.Prepare(source, renderfs.Options{})
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	for _, name := range []string{"alice", "bob"} {
+		writer := writers.NewMemoryWriter()
+		if err := prepared.Render(pongo2.Context{"name": name}, writer); err != nil {
+			t.Fatalf("Render(%s) failed: %v", name, err)
+		}
+
+		contents := writer.Contents()
+		if len(contents) != 1 {
+			t.Fatalf("expected a single file, got %v", contents)
+		}
+		if got := string(contents[name+".txt"]); got != "hello "+name+"\n" {
+			t.Fatalf("unexpected content for %s: %q", name, got)
+		}
+	}
+
+	if err := prepared.Render(pongo2.Context{}, writers.NewMemoryWriter()); err == nil {
+		t.Fatalf("expected missing variable error")
+	}
+}
+
+func TestPrepareReportsCompileErrors(t *testing.T) {
+	source := fstest.MapFS{
+		"broken.txt": {
+			Data: []byte("{% if %}"),
+		},
+	}
+
+	if _, err := renderfs.Prepare(source, renderfs.Options{}); err == nil {
+		t.Fatalf("expected compile error from Prepare")
+	}
+}
+
+func TestPrepareAppliesCopySourceRules(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte("Hello {{ name }}\n")); err != nil {
+		t.Fatalf("gzip write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	source := fstest.MapFS{
+		"greeting.txt.gz":     {Data: compressed.Bytes()},
+		".renderfs-data.yaml": {Data: []byte("title: \"{% not a template\"\n")},
+	}
+	opts := renderfs.Options{
+		DirectoryData: true,
+		ContentDecoders: map[string]func(io.Reader) (io.Reader, error){
+			".gz": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		},
+	}
+
+	prepared, err := renderfs.Prepare(source, opts)
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	writer := writers.NewMemoryWriter()
+	if err := prepared.Render(pongo2.Context{"name": "demo"}, writer); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if got := string(writer.Contents()["greeting.txt"]); got != "Hello demo\n" {
+		t.Fatalf("unexpected decoded output %q", got)
+	}
+}
+
+func benchmarkSource() fstest.MapFS {
+	source := fstest.MapFS{}
+	for i := 0; i < 50; i++ {
+		source[fmt.Sprintf("dir%d/{{ name }}-%d.txt.jinja", i%5, i)] = &fstest.MapFile{
+			Data: []byte(fmt.Sprintf("file %d for {{ name }}\n{%% if flag %%}on{%% endif %%}\n", i)),
+			Mode: 0o644,
+		}
+	}
+	for i := 0; i < 5; i++ {
+		source[fmt.Sprintf("dir%d", i)] = &fstest.MapFile{Mode: fs.ModeDir | 0o755}
+	}
+	return source
+}
+
+func BenchmarkRepeatedCopy(b *testing.B) {
+	source := benchmarkSource()
+	opts := renderfs.Options{
+		Context:        pongo2.Context{"name": "bench", "flag": true},
+		IgnorePatterns: []string{"*.bak"},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPreparedRender(b *testing.B) {
+	prepared, err := renderfs.Prepare(benchmarkSource(), renderfs.Options{IgnorePatterns: []string{"*.bak"}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := pongo2.Context{"name": "bench", "flag": true}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := prepared.Render(ctx, writers.NewMemoryWriter()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCopyManyRendersEachTarget(t *testing.T) {
+	source := fstest.MapFS{
+		"config.yaml.tmpl": {
+			Data: []byte("tenant: {{ tenant }}\n"),
+			Mode: 0o644,
+		},
+	}
+
+	dirs := []string{t.TempDir(), t.TempDir()}
+	tenants := []string{"acme", "globex"}
+	targets := make([]renderfs.Target, len(dirs))
+	for i, dir := range dirs {
+		writer, err := writers.NewOSWriter(dir)
+		if err != nil {
+			t.Fatalf("NewOSWriter: %v", err)
+		}
+		targets[i] = renderfs.Target{Context: pongo2.Context{"tenant": tenants[i]}, Dest: writer}
+	}
+
+	if err := renderfs.CopyMany(source, targets, renderfs.Options{}); err != nil {
+		t.Fatalf("CopyMany failed: %v", err)
+	}
+
+	for i, dir := range dirs {
+		content, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+		if err != nil {
+			t.Fatalf("read output %d: %v", i, err)
+		}
+		if want := "tenant: " + tenants[i] + "\n"; string(content) != want {
+			t.Fatalf("unexpected output %d: %q", i, content)
+		}
+	}
+}
+
+func TestPreparedRenderTransactional(t *testing.T) {
+	source := fstest.MapFS{
+		"a.txt":     {Data: []byte("{{ name }} a")},
+		"z/last.md": {Data: []byte("{{ name }} last")},
+	}
+	opts := renderfs.Options{
+		Transactional: true,
+		OnFile: func(event renderfs.FileEvent) error {
+			if event.Path == "z/last.md" {
+				return errors.New("late failure")
+			}
+			return nil
+		},
+	}
+	prepared, err := renderfs.Prepare(source, opts)
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := prepared.Render(pongo2.Context{"name": "demo"}, writer); err == nil {
+		t.Fatalf("expected late failure")
+	}
+	if got := writer.Contents(); len(got) != 0 {
+		t.Fatalf("expected destination untouched, got %v", got)
+	}
+
+	targets := []renderfs.Target{{Context: pongo2.Context{"name": "demo"}, Dest: writers.NewMemoryWriter()}}
+	if err := renderfs.CopyMany(source, targets, opts); err == nil {
+		t.Fatalf("expected late failure from CopyMany")
+	}
+	if got := targets[0].Dest.(*writers.MemoryWriter).Contents(); len(got) != 0 {
+		t.Fatalf("expected CopyMany destination untouched, got %v", got)
+	}
+}
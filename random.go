@@ -0,0 +1,63 @@
+package renderfs
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// randomHelpers backs the uuid, randint, and choice template functions with
+// one generator per copy, so a fixed Options.RandSeed reproduces their output.
+type randomHelpers struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newRandomHelpers(seed int64) *randomHelpers {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &randomHelpers{rng: rand.New(rand.NewPCG(uint64(seed), 0))}
+}
+
+// uuid returns a random version 4 UUID.
+func (h *randomHelpers) uuid() string {
+	h.mu.Lock()
+	hi, lo := h.rng.Uint64(), h.rng.Uint64()
+	h.mu.Unlock()
+
+	var b [16]byte
+	for i := 0; i < 8; i++ {
+		b[i] = byte(hi >> (56 - 8*i))
+		b[8+i] = byte(lo >> (56 - 8*i))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// randint returns a random integer between lo and hi inclusive.
+func (h *randomHelpers) randint(lo, hi int) (int, error) {
+	if hi < lo {
+		return 0, fmt.Errorf("renderfs: randint: empty range [%d, %d]", lo, hi)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return lo + h.rng.IntN(hi-lo+1), nil
+}
+
+// choice returns a random element of a slice or array.
+func (h *randomHelpers) choice(items interface{}) (interface{}, error) {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("renderfs: choice: %T is not a list", items)
+	}
+	if v.Len() == 0 {
+		return nil, fmt.Errorf("renderfs: choice: empty list")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return v.Index(h.rng.IntN(v.Len())).Interface(), nil
+}
@@ -0,0 +1,66 @@
+package renderfs
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// renameMapper returns a path mapper applying Options.Rename. Literal keys are
+// tried before glob patterns, each group in sorted order, and the first match
+// wins. Keys without a slash match and replace the base name only.
+func renameMapper(rules map[string]string) func(string, bool) (string, bool, error) {
+	patterns := make([]string, 0, len(rules))
+	for pattern := range rules {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		gi, gj := isGlob(patterns[i]), isGlob(patterns[j])
+		if gi != gj {
+			return gj
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	return func(rel string, _ bool) (string, bool, error) {
+		for _, pattern := range patterns {
+			if !matchGlob(pattern, rel) {
+				continue
+			}
+			base := path.Base(rel)
+			ext := path.Ext(base)
+			target := strings.NewReplacer(
+				"{stem}", strings.TrimSuffix(base, ext),
+				"{ext}", ext,
+			).Replace(rules[pattern])
+
+			if strings.Contains(pattern, "/") {
+				return target, false, nil
+			}
+			return path.Join(path.Dir(rel), target), false, nil
+		}
+		return rel, false, nil
+	}
+}
+
+// compileRedirects cleans the keys and targets of Options.Redirects and
+// rejects targets that are empty or escape the destination.
+func compileRedirects(redirects map[string]string) (map[string]string, error) {
+	compiled := make(map[string]string, len(redirects))
+	for from, to := range redirects {
+		target := path.Clean(strings.ReplaceAll(strings.TrimSpace(to), "\\", "/"))
+		if target == "." {
+			return nil, fmt.Errorf("renderfs: redirect for %s is empty", from)
+		}
+		if escapesRoot(target) {
+			return nil, fmt.Errorf("renderfs: redirect %q for %s escapes destination", to, from)
+		}
+		compiled[path.Clean(strings.ReplaceAll(from, "\\", "/"))] = target
+	}
+	return compiled, nil
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
@@ -0,0 +1,70 @@
+package renderfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// RenderFile renders the single source file at rel as Copy would, without
+// writing anything, and returns its destination-relative name, content, and
+// mode. Path rendering, suffix stripping, the missing-variable check, and the
+// other content options all apply. When Copy would not produce the file, for
+// example because it is ignored or its path renders empty, name is empty and
+// content is nil. Destination-dependent options (conflicts, merges,
+// SkipUnchanged, PriorManifest) see an empty destination, and ManifestWriter
+// and DryRun are ignored.
+func RenderFile(source fs.FS, rel string, opts Options) (name string, content []byte, mode fs.FileMode, err error) {
+	if source == nil {
+		return "", nil, 0, fmt.Errorf("renderfs: source filesystem is required")
+	}
+	info, err := fs.Stat(source, rel)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("renderfs: stat %s: %w", rel, err)
+	}
+	if info.IsDir() {
+		return "", nil, 0, fmt.Errorf("renderfs: %s is a directory", rel)
+	}
+
+	matcher, err := buildIgnoreMatcher(source, opts)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	opts.ManifestWriter = nil
+	opts.DryRun = false
+
+	capture := &captureWriter{files: make(map[string][]byte), modes: make(map[string]fs.FileMode)}
+	c, err := newCopier(source, capture, opts, matcher, newRenderer(source, opts))
+	if err != nil {
+		return "", nil, 0, err
+	}
+	// Visit the ancestors first, as the walk would, so a directory that is
+	// ignored, renders empty, or has its contents skipped takes rel with it.
+	if dir := path.Dir(rel); dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			ancestor := strings.Join(parts[:i+1], "/")
+			dirInfo, err := fs.Stat(source, ancestor)
+			if err != nil {
+				return "", nil, 0, fmt.Errorf("renderfs: stat %s: %w", ancestor, err)
+			}
+			if err := c.visit(ancestor, fs.FileInfoToDirEntry(dirInfo), nil); err != nil {
+				if errors.Is(err, fs.SkipDir) {
+					return "", nil, 0, nil
+				}
+				return "", nil, 0, err
+			}
+		}
+	}
+	if err := c.visit(rel, fs.FileInfoToDirEntry(info), nil); err != nil {
+		return "", nil, 0, err
+	}
+
+	for p, data := range capture.files {
+		return p, data, capture.modes[p], nil
+	}
+	return "", nil, 0, nil
+}
@@ -0,0 +1,76 @@
+package renderfs_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+
+	"github.com/your-org/renderfs"
+)
+
+func TestRenderFile(t *testing.T) {
+	source := fstest.MapFS{
+		"README.md":                        {Data: []byte("# {{ name }}")},
+		"cmd/{{ name }}/main.go.jinja":     {Data: []byte("package main // {{ name }}"), Mode: 0o755},
+		"cmd/{{ name }}/other.go":          {Data: []byte("{{ missing }}")},
+		"{% if false %}skipped{% endif %}": {Data: []byte("never")},
+	}
+	opts := renderfs.Options{Context: pongo2.Context{"name": "tool"}}
+
+	name, content, mode, err := renderfs.RenderFile(source, "cmd/{{ name }}/main.go.jinja", opts)
+	if err != nil {
+		t.Fatalf("RenderFile failed: %v", err)
+	}
+	if name != "cmd/tool/main.go" || string(content) != "package main // tool" || mode != 0o755 {
+		t.Fatalf("unexpected result %q %q %v", name, content, mode)
+	}
+
+	_, _, _, err = renderfs.RenderFile(source, "cmd/{{ name }}/other.go", opts)
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected missing variable error, got %v", err)
+	}
+
+	name, content, _, err = renderfs.RenderFile(source, "{% if false %}skipped{% endif %}", opts)
+	if err != nil || name != "" || content != nil {
+		t.Fatalf("expected skipped file to render nothing, got %q %q %v", name, content, err)
+	}
+
+	if _, _, _, err := renderfs.RenderFile(source, "cmd", opts); err == nil {
+		t.Fatalf("expected directories to be rejected")
+	}
+	if _, _, _, err := renderfs.RenderFile(source, "nope.txt", opts); err == nil {
+		t.Fatalf("expected missing file to fail")
+	}
+}
+
+func TestRenderFileSkipsWithAncestors(t *testing.T) {
+	source := fstest.MapFS{
+		"{% if docs %}docs{% endif %}/guide.md": {Data: []byte("guide\n")},
+		"a/.renderfs-if":                        {Data: []byte("{% if examples %}yes{% endif %}")},
+		"a/b/c.txt":                             {Data: []byte("{{ name }}")},
+	}
+	opts := renderfs.Options{
+		Context:             pongo2.Context{"name": "tool", "docs": false, "examples": false},
+		DirectoryConditions: true,
+	}
+
+	for _, rel := range []string{"{% if docs %}docs{% endif %}/guide.md", "a/b/c.txt"} {
+		name, content, _, err := renderfs.RenderFile(source, rel, opts)
+		if err != nil || name != "" || content != nil {
+			t.Fatalf("expected %s to render nothing, got %q %q %v", rel, name, content, err)
+		}
+	}
+
+	opts.Context["docs"] = true
+	opts.Context["examples"] = true
+	name, _, _, err := renderfs.RenderFile(source, "{% if docs %}docs{% endif %}/guide.md", opts)
+	if err != nil || name != "docs/guide.md" {
+		t.Fatalf("expected docs/guide.md, got %q %v", name, err)
+	}
+	name, content, _, err := renderfs.RenderFile(source, "a/b/c.txt", opts)
+	if err != nil || name != "a/b/c.txt" || string(content) != "tool" {
+		t.Fatalf("expected a/b/c.txt, got %q %q %v", name, content, err)
+	}
+}
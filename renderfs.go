@@ -1,8 +1,10 @@
 package renderfs
 
 import (
+	"fmt"
 	"io"
 	"io/fs"
+	"time"
 
 	"github.com/flosch/pongo2/v6"
 )
@@ -19,12 +21,67 @@ const (
 	Fail
 )
 
+var conflictNames = [...]string{Overwrite: "overwrite", Skip: "skip", Fail: "fail"}
+
+// String returns "overwrite", "skip", or "fail".
+func (r ConflictResolution) String() string {
+	if r >= 0 && int(r) < len(conflictNames) {
+		return conflictNames[r]
+	}
+	return fmt.Sprintf("ConflictResolution(%d)", int(r))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r ConflictResolution) MarshalText() ([]byte, error) {
+	if r < 0 || int(r) >= len(conflictNames) {
+		return nil, fmt.Errorf("renderfs: unknown conflict resolution %d", int(r))
+	}
+	return []byte(conflictNames[r]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using
+// ParseConflictResolution.
+func (r *ConflictResolution) UnmarshalText(text []byte) error {
+	parsed, err := ParseConflictResolution(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// LineEnding selects how line endings in rendered text files are written.
+type LineEnding int
+
+const (
+	// PreserveLineEndings writes line endings exactly as rendered.
+	PreserveLineEndings LineEnding = iota
+	// LF converts line endings to "\n".
+	LF
+	// CRLF converts line endings to "\r\n".
+	CRLF
+)
+
 // Options configures the behaviour of the Copy operation.
 type Options struct {
 	// Context provides template data when rendering path and file contents.
-	// When nil, an empty context is used.
+	// When nil, an empty context is used. Values of type
+	// func() (interface{}, error), including those in nested maps, are
+	// evaluated lazily: only when a template references them, and at most
+	// once per copy.
 	Context pongo2.Context
 
+	// ContextChain supplies fallback contexts consulted in order when a key
+	// is missing from Context. Earlier entries take precedence, and nested
+	// maps fall through key by key, so an override can replace a single
+	// nested value without repeating its siblings.
+	ContextChain []pongo2.Context
+
+	// PathContext, when set, is used instead of Context (and ContextChain) to
+	// render source paths into destination paths, so variables that only
+	// shape file names stay out of the content context.
+	PathContext pongo2.Context
+
 	// OnConflict controls how Copy reacts when the destination file already exists.
 	// Defaults to Overwrite when left zero-valued.
 	OnConflict ConflictResolution
@@ -33,6 +90,399 @@ type Options struct {
 	// from the copy. When empty, Copy looks for a .renderfs-ignore file at the
 	// root of the source filesystem.
 	IgnorePatterns []string
+
+	// RenderIgnorePatterns renders each of IgnorePatterns as a template with
+	// the copy context before compiling it, so "{{ build_dir }}/" can follow
+	// configuration. Patterns read from .renderfs-ignore are used as written.
+	RenderIgnorePatterns bool
+
+	// Ignore supplies rules precompiled with CompileIgnore. When set, it
+	// replaces IgnorePatterns, UseGitignore, and reading .renderfs-ignore.
+	Ignore *IgnoreMatcher
+
+	// IncludeIgnoreFile copies the source's .renderfs-ignore file to the
+	// destination like any other file instead of leaving it out. Its patterns
+	// still apply.
+	IncludeIgnoreFile bool
+
+	// UseGitignore additionally reads a .gitignore file at the source root
+	// when IgnorePatterns is empty. The .gitignore file itself is then left
+	// out of the output.
+	UseGitignore bool
+
+	// Flatten writes every file and symlink directly into the destination
+	// root under the base name of its rendered path. No directories are
+	// created and keep markers are dropped. Two entries flattening to the
+	// same name fail the copy.
+	Flatten bool
+
+	// MaxNameLength limits the length in bytes of each element of a rendered
+	// destination path, so a runaway template fails with a clear error rather
+	// than one from the filesystem. Defaults to 255, the common filesystem
+	// limit; a negative value disables the check.
+	MaxNameLength int
+
+	// MaxDepth, when positive, limits how deep the walk goes: entries more
+	// than MaxDepth levels below the source root are skipped, so 1 copies
+	// only top-level files and directories, leaving the directories empty.
+	MaxDepth int
+
+	// IgnoreRenderedPatterns contains gitignore-style patterns matched against
+	// the rendered destination path (after template suffixes are stripped).
+	// Matching directories are skipped along with their contents.
+	IgnoreRenderedPatterns []string
+
+	// VerbatimPatterns contains gitignore-style patterns matched against
+	// source paths. Matching files are copied byte for byte: neither their
+	// paths nor their contents are rendered, so no template suffix is
+	// stripped and no variables are checked. Conflict handling, Rename, and
+	// PathMapper still apply. Ignore rules take precedence, so list a tree
+	// such as vendor/ here instead of ignoring it.
+	VerbatimPatterns []string
+
+	// ManifestWriter, when set, receives a sha256sum-style listing of every
+	// file written by Copy, sorted by destination path. Checksums are computed
+	// over the rendered content.
+	ManifestWriter io.Writer
+
+	// SkipUnchanged avoids rewriting destination files whose content and
+	// permissions already match the rendered output, preserving their
+	// modification times. It requires a Writer that supports Lstat and
+	// ReadFile; other writers always receive the write.
+	SkipUnchanged bool
+
+	// PriorManifest supplies the ManifestWriter output of an earlier run.
+	// Files whose rendered checksum matches their prior entry are not
+	// rewritten, without reading the destination back. Writers that support
+	// Lstat are still consulted so that deleted files are recreated.
+	PriorManifest io.Reader
+
+	// RenderSymlinkTargets renders symlink targets as templates. Rendered
+	// targets must stay within the destination; absolute targets and targets
+	// that climb above the root are rejected. Defaults to copying targets
+	// verbatim.
+	RenderSymlinkTargets bool
+
+	// StrictSubscripts tightens the missing-variable check for subscripts it
+	// cannot evaluate statically, such as items[i]. When the index names a
+	// context value it is resolved and bounds- or key-checked; otherwise the
+	// indexed value must at least be a slice, array, string, or map. By
+	// default such subscripts are assumed to be available.
+	StrictSubscripts bool
+
+	// KnownGlobals lists additional top-level names the missing-variable
+	// check should treat as always available, such as globals or context
+	// processors registered with pongo2 directly. Copy does not provide
+	// their values.
+	KnownGlobals []string
+
+	// ExtraKeywords lists the names of custom tags and their keywords, such
+	// as a tag registered with pongo2.RegisterTag, so the missing-variable
+	// check does not mistake them for context variables. Names closing a
+	// block tag (end + keyword) are already ignored.
+	ExtraKeywords []string
+
+	// PrecompileTemplates compiles every path, file, and rendered symlink
+	// target template before anything is written, so syntax errors and
+	// unknown filters or tags fail the copy up front, attributed to their
+	// source path, instead of after part of the tree has been written.
+	PrecompileTemplates bool
+
+	// FollowSymlinks copies what source symlinks point to instead of the links
+	// themselves: file targets are rendered as regular files, and directory
+	// targets are rendered in full under the link's path. Targets must resolve
+	// within the source filesystem.
+	FollowSymlinks bool
+
+	// FailOnSymlinkLoop makes a followed directory link that leads back to
+	// one of its own ancestors an error. By default such links are skipped.
+	FailOnSymlinkLoop bool
+
+	// DirectoryData lets a source directory carry a .renderfs-data.yaml
+	// mapping whose values are layered over the context for every path and
+	// file within it, with deeper data files overriding shallower ones and
+	// nested maps merged key by key. Data files themselves are not copied.
+	// Without it, such files are copied like any other.
+	DirectoryData bool
+
+	// DirectoryConditions lets a source directory carry a .renderfs-if
+	// template deciding whether its contents are copied. The directory itself
+	// is always created; when the template renders blank, everything below
+	// it is skipped. Without it, such files are copied like any other.
+	DirectoryConditions bool
+
+	// FrontMatter enables per-file context overrides. When a file starts with
+	// a `---` fenced YAML block, its values are layered over Context for that
+	// file only and the block is stripped from the output.
+	FrontMatter bool
+
+	// StrictSuffix rejects directories whose source name ends in a template
+	// suffix (.jinja or .tmpl). Suffixes are only stripped from files, so such
+	// a directory is almost always a mistake.
+	StrictSuffix bool
+
+	// OnceGlobs lists glob patterns, matched like MergeFuncs keys, for files
+	// that are only written when they do not exist yet, such as a config the
+	// user is expected to edit. An existing match is left untouched whatever
+	// OnConflict says, and takes precedence over MergeFuncs. Requires a
+	// Writer that supports Lstat.
+	OnceGlobs []string
+
+	// AppendGlobs lists glob patterns, matched like MergeFuncs keys, for
+	// files whose rendered content is appended to the destination file
+	// instead of replacing it, so several source fragments, or several runs,
+	// accumulate into one file. Appended files bypass OnConflict, MergeFuncs,
+	// SkipUnchanged, and the manifests; OnceGlobs still applies. Requires a
+	// Writer with an AppendFile(path string, perm fs.FileMode)
+	// (io.WriteCloser, error) method, which the writers package provides.
+	AppendGlobs []string
+
+	// MergeFuncs maps glob patterns (matched against the rendered destination
+	// path, or its base name for patterns without a slash) to functions that
+	// combine an existing destination file with the newly rendered content.
+	// When a matching file already exists, the merge result is written
+	// regardless of OnConflict. Merging requires a Writer that supports Lstat
+	// and ReadFile.
+	MergeFuncs map[string]MergeFunc
+
+	// ValidateStructured parses rendered files whose destination name ends in
+	// .json, .yaml, or .yml, after Transformers run, and fails the copy when
+	// one does not parse, before it is written.
+	ValidateStructured bool
+
+	// ContentDecoders decode source content before it is rendered, keyed by a
+	// file name suffix including the dot (".gz"). The suffix may be followed
+	// by a template suffix, as in config.yaml.gz.tmpl, and is stripped from
+	// the destination name along with it. When several suffixes match, the
+	// longest wins.
+	ContentDecoders map[string]func(io.Reader) (io.Reader, error)
+
+	// Transformers post-process rendered content before it is written, keyed
+	// by the extension of the destination name including the dot (".go"),
+	// after any template suffix has been stripped. Merging, if configured,
+	// sees the transformed content.
+	Transformers map[string]func([]byte) ([]byte, error)
+
+	// SkipEmptyFiles leaves out files whose rendered content is empty or only
+	// whitespace, such as a file wrapped entirely in a false {% if %}. This
+	// mirrors how an empty rendered path skips its entry.
+	SkipEmptyFiles bool
+
+	// LineEndings normalizes line endings in rendered text files. Files that
+	// look binary (containing a NUL byte near the start) are left untouched.
+	// Defaults to PreserveLineEndings.
+	LineEndings LineEnding
+
+	// EnsureFinalNewline terminates non-empty rendered text files that do
+	// not already end in a newline with exactly one, in the LineEndings
+	// style. Empty and binary files are left as they are.
+	EnsureFinalNewline bool
+
+	// MaxRenderedSize, when positive, caps the rendered size of each file in
+	// bytes; exceeding it aborts the copy. pongo2 renders into memory, so the
+	// limit is checked once rendering completes rather than while streaming.
+	MaxRenderedSize int64
+
+	// MaxSourceFileSize, when positive, skips source files larger than this
+	// many bytes before they are read, judged by their size as reported by
+	// the source filesystem. Unlike MaxRenderedSize, which caps output, it
+	// protects against oversized input such as a hostile template.
+	MaxSourceFileSize int64
+
+	// FailOnOversizedSource makes a file exceeding MaxSourceFileSize abort
+	// the copy instead of being skipped.
+	FailOnOversizedSource bool
+
+	// RenderTimeout, when positive, bounds how long a single template may take
+	// to render. pongo2 cannot be interrupted, so an execution that times out
+	// is abandoned in the background while Copy returns an error promptly.
+	RenderTimeout time.Duration
+
+	// OnFileRendered, when set, is called after each file's content renders
+	// with its source-relative path, the time spent rendering, and the
+	// rendered size in bytes. CopyMany may call it from several goroutines.
+	OnFileRendered func(rel string, dur time.Duration, size int)
+
+	// KeepMarker names the placeholder file used to keep otherwise empty
+	// directories in a template repository. Defaults to ".gitkeep".
+	KeepMarker string
+
+	// DropKeepMarker omits KeepMarker files from the output while still
+	// creating the directories that contain them.
+	DropKeepMarker bool
+
+	// DirModeFunc, when set, decides the permissions of each directory Copy
+	// creates. It receives the source-relative path and source file info and
+	// overrides the mode that would otherwise be copied from the source.
+	DirModeFunc func(rel string, info fs.FileInfo) fs.FileMode
+
+	// DirModeOverrides maps glob patterns, matched like MergeFuncs keys
+	// against the rendered directory path, to the permissions of matching
+	// directories, as in {"bin": 0o755}. They take precedence over the
+	// source mode and DirModeFunc, which helps with sources such as embed.FS
+	// that do not preserve modes.
+	DirModeOverrides map[string]fs.FileMode
+
+	// Redirects maps source-relative file paths to destination-relative
+	// paths that replace them before path rendering, so a file can land
+	// anywhere in the destination regardless of where it lives in the
+	// source, as in {"templates/ci.yml": ".github/workflows/ci.yml"}.
+	// Targets are templates like any source path and are rejected when they
+	// escape the destination. Directory keys are ignored; Rename and
+	// PathMapper still apply to the redirected path.
+	Redirects map[string]string
+
+	// Rename maps rendered destination paths to new names. Keys are literal
+	// paths or path.Match globs; keys without a slash match the base name at
+	// any depth and replace only the base name. In the replacement, {stem}
+	// and {ext} stand for the matched base name without and with only its
+	// last extension, so "*.sample": "{stem}" turns config.sample into
+	// config. Literal keys take precedence over globs. Renamed paths are
+	// escape-checked like rendered paths and are seen by PathMapper.
+	Rename map[string]string
+
+	// PathMapper, when set, rewrites each rendered destination path before it
+	// is written. Returning skip drops the entry (and, for a directory, its
+	// contents); returning a different path redirects it. Mapped paths are
+	// subject to the same escape checks as rendered paths, and
+	// IgnoreRenderedPatterns is matched against the mapped path.
+	PathMapper func(renderedRel string, isDir bool) (mapped string, skip bool, err error)
+
+	// RandSeed seeds the randomness behind the uuid(), randint(lo, hi), and
+	// choice(list) template functions, so copies with the same seed and
+	// inputs render identical output. Zero seeds them from the clock. It only
+	// affects these renderfs-provided helpers, not filters registered with
+	// pongo2 directly.
+	RandSeed int64
+
+	// EnvVars lists the environment variables exposed to templates under env,
+	// as in {{ env.HOME }}. Only listed variables that are set are included,
+	// so referencing an unset one fails the missing-variable check. Nothing
+	// from the environment is exposed by default.
+	EnvVars []string
+
+	// Translations maps locales to message keys to text for the t template
+	// function: {{ t('welcome') }} yields the Locale entry for welcome. A key
+	// missing for Locale is looked up in FallbackLocale when set, and
+	// otherwise fails the render. t is only provided when Translations is set.
+	Translations map[string]map[string]string
+
+	// Locale selects the Translations entry t reads from.
+	Locale string
+
+	// FallbackLocale is consulted for keys Locale does not translate.
+	FallbackLocale string
+
+	// Now is the generation time exposed to templates as now, for example
+	// {{ now|date:"2006-01-02" }}. When zero, Copy uses the time it starts,
+	// so every file in one copy sees the same timestamp; set it to freeze the
+	// clock for reproducible output.
+	Now time.Time
+
+	// SnippetFS, when set, is searched for include, extends, and import
+	// names that the source filesystem does not contain, so shared snippets
+	// such as license headers can live outside the template tree. Files in
+	// SnippetFS are never copied.
+	SnippetFS fs.FS
+
+	// ExposeFileList makes the sorted list of destination file paths
+	// available to content templates as __files__. The paths are computed in
+	// a path-only pass before any content renders, so they reflect path
+	// rendering, ignore rules, Rename, and PathMapper, but not files later
+	// dropped by conflict handling or SkipEmptyFiles.
+	ExposeFileList bool
+
+	// ExposeSource makes each file's raw source, before front matter and
+	// mode directives are stripped or anything renders, available to its
+	// content template as __source__, for headers that quote the template a
+	// file was produced from.
+	ExposeSource bool
+
+	// DryRun renders everything and runs the hooks but leaves the destination
+	// untouched: no directories, files, or symlinks are created or removed.
+	// The destination is still consulted for conflicts and merges, and
+	// CopyResult counts the files that would have been written.
+	DryRun bool
+
+	// Transactional makes the copy all-or-nothing: output is staged and only
+	// published at the destination once the whole copy, including the
+	// hooks, succeeds, and discarded otherwise. The destination Writer must
+	// implement Stager. It has no effect in a dry run.
+	Transactional bool
+
+	// OnFile, when set, is called for each file once it has been written, or
+	// would have been in a dry run. Returning an error aborts the copy.
+	OnFile func(event FileEvent) error
+
+	// OnSkip, when set, is called with the source-relative path of each
+	// entry Copy leaves out and the reason. A skipped directory is reported
+	// once, without its contents.
+	OnSkip func(rel string, reason SkipReason)
+
+	// AfterCopy, when set, is called once the walk completes successfully
+	// with the result and whether this was a dry run. Its error is returned
+	// by Copy.
+	AfterCopy func(result CopyResult, dryRun bool) error
+}
+
+// FileEvent describes a file passed to Options.OnFile.
+type FileEvent struct {
+	// Source is the source-relative path of the template.
+	Source string
+
+	// Path is the destination-relative path the file is written to.
+	Path string
+
+	// Mode holds the permission bits the file is created with.
+	Mode fs.FileMode
+
+	// Size is the number of bytes written.
+	Size int64
+
+	// DryRun reports that nothing was written; hooks should avoid side
+	// effects that assume the file exists.
+	DryRun bool
+}
+
+// MergeFunc combines the existing destination content with freshly rendered
+// content and returns the bytes to write.
+type MergeFunc func(existing, rendered []byte) ([]byte, error)
+
+// CopyResult summarises the work performed by a copy.
+type CopyResult struct {
+	// FilesWritten counts files created or overwritten at the destination.
+	FilesWritten int
+
+	// SkippedUnchanged counts files left untouched because SkipUnchanged or
+	// PriorManifest showed the destination already held the rendered content.
+	SkippedUnchanged int
+
+	// SkippedEmpty counts files not written because SkipEmptyFiles is set and
+	// they rendered to nothing but whitespace.
+	SkippedEmpty int
+
+	// SkippedOversized counts source files not read because they exceeded
+	// MaxSourceFileSize.
+	SkippedOversized int
+}
+
+// Stager is implemented by Writers that support Options.Transactional.
+type Stager interface {
+	// Stage returns a writer whose output stays invisible at the destination
+	// until it is committed. Its Lstat and ReadFile, when implemented, must
+	// report the destination as it would look after Commit.
+	Stage() (StagedWriter, error)
+}
+
+// StagedWriter collects output for a transactional copy.
+type StagedWriter interface {
+	Writer
+
+	// Commit publishes everything written to the stage at the destination.
+	Commit() error
+
+	// Rollback discards the stage, leaving the destination untouched.
+	Rollback() error
 }
 
 // Writer abstracts the destination that rendered files and directories are
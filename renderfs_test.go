@@ -1,9 +1,24 @@
 package renderfs_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
 	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/your-org/renderfs"
@@ -157,3 +172,2662 @@ func TestCopyFailsOnMissingVariable(t *testing.T) {
 		t.Fatalf("expected missing variable error")
 	}
 }
+
+func TestCopyWritesManifest(t *testing.T) {
+	source := fstest.MapFS{
+		"b.txt.jinja": {
+			Data: []byte("hello {{ name }}\n"),
+		},
+		"a/c.txt": {
+			Data: []byte("static\n"),
+		},
+	}
+
+	var manifest strings.Builder
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{
+		Context:        pongo2.Context{"name": "world"},
+		ManifestWriter: &manifest,
+	}
+
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	sum := func(s string) string {
+		h := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(h[:])
+	}
+	want := sum("static\n") + "  a/c.txt\n" + sum("hello world\n") + "  b.txt\n"
+	if got := manifest.String(); got != want {
+		t.Fatalf("unexpected manifest:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCopySkipUnchangedPreservesMtime(t *testing.T) {
+	source := fstest.MapFS{
+		"config.yaml.tmpl": {
+			Data: []byte("name: {{ name }}\n"),
+			Mode: 0o644,
+		},
+	}
+
+	dest := t.TempDir()
+	writer, err := writers.NewOSWriter(dest)
+	if err != nil {
+		t.Fatalf("NewOSWriter: %v", err)
+	}
+	opts := renderfs.Options{
+		Context:       pongo2.Context{"name": "demo"},
+		SkipUnchanged: true,
+	}
+
+	first, err := renderfs.CopyWithResult(source, writer, opts)
+	if err != nil {
+		t.Fatalf("first Copy failed: %v", err)
+	}
+	if first.FilesWritten != 1 {
+		t.Fatalf("expected 1 file written, got %d", first.FilesWritten)
+	}
+
+	target := filepath.Join(dest, "config.yaml")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(target, past, past); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	second, err := renderfs.CopyWithResult(source, writer, opts)
+	if err != nil {
+		t.Fatalf("second Copy failed: %v", err)
+	}
+	if second.FilesWritten != 0 || second.SkippedUnchanged != 1 {
+		t.Fatalf("expected nothing written and 1 unchanged, got %+v", second)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Fatalf("expected mtime %v to be preserved, got %v", past, info.ModTime())
+	}
+}
+
+type auditInfo struct {
+	CreatedBy string
+}
+
+func (a *auditInfo) Owner() string { return a.CreatedBy }
+
+type project struct {
+	auditInfo
+	Name string
+}
+
+func TestCopyResolvesPromotedFieldsAndMethods(t *testing.T) {
+	source := fstest.MapFS{
+		"owner.txt": {
+			Data: []byte("{{ project.Name }} by {{ project.CreatedBy }} ({{ project.Owner }})"),
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	context := pongo2.Context{
+		"project": &project{auditInfo: auditInfo{CreatedBy: "alice"}, Name: "demo"},
+	}
+
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: context}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	if got := string(writer.Contents()["owner.txt"]); got != "demo by alice (alice)" {
+		t.Fatalf("unexpected content: %q", got)
+	}
+}
+
+func TestCopyFailsOnMissingNestedAttribute(t *testing.T) {
+	source := fstest.MapFS{
+		"owner.txt": {
+			Data: []byte("{{ project.Missing }}"),
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	context := pongo2.Context{"project": &project{Name: "demo"}}
+
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: context}); err == nil {
+		t.Fatalf("expected missing attribute error")
+	}
+}
+
+func TestCopyRendersSymlinkTargets(t *testing.T) {
+	source := fstest.MapFS{
+		"current": {
+			Data: []byte("releases/{{ version }}"),
+			Mode: fs.ModeSymlink | 0o777,
+		},
+	}
+	context := pongo2.Context{"version": "1.2.3"}
+
+	dest := t.TempDir()
+	writer, err := writers.NewOSWriter(dest)
+	if err != nil {
+		t.Fatalf("NewOSWriter: %v", err)
+	}
+
+	opts := renderfs.Options{Context: context, RenderSymlinkTargets: true}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	target, err := os.Readlink(filepath.Join(dest, "current"))
+	if err != nil {
+		t.Fatalf("Readlink: %v", err)
+	}
+	if target != "releases/1.2.3" {
+		t.Fatalf("unexpected link target: %q", target)
+	}
+
+	escaping := fstest.MapFS{
+		"current": {
+			Data: []byte("../{{ version }}"),
+			Mode: fs.ModeSymlink | 0o777,
+		},
+	}
+	if err := renderfs.Copy(escaping, writers.NewMemoryWriter(), opts); err == nil {
+		t.Fatalf("expected escaping symlink target to be rejected")
+	}
+}
+
+func TestCopyAppliesFrontMatter(t *testing.T) {
+	source := fstest.MapFS{
+		"page.md.jinja": {
+			Data: []byte("---\ntitle: Hello\nauthor:\n  name: Ada\n---\n# {{ title }} by {{ author.name }} for {{ site }}\n"),
+		},
+		"other.md.jinja": {
+			Data: []byte("{{ site }}\n"),
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{
+		Context:     pongo2.Context{"site": "docs", "title": "Default"},
+		FrontMatter: true,
+	}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	contents := writer.Contents()
+	if got := string(contents["page.md"]); got != "# Hello by Ada for docs\n" {
+		t.Fatalf("unexpected page content: %q", got)
+	}
+	if got := string(contents["other.md"]); got != "docs\n" {
+		t.Fatalf("unexpected other content: %q", got)
+	}
+}
+
+func TestCopyStrictSuffixRejectsTemplatedDirectory(t *testing.T) {
+	source := fstest.MapFS{
+		"x.tmpl": {
+			Mode: fs.ModeDir | 0o755,
+		},
+		"x.tmpl/file.txt": {
+			Data: []byte("content"),
+		},
+	}
+
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{}); err != nil {
+		t.Fatalf("expected lenient copy to succeed: %v", err)
+	}
+
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{StrictSuffix: true})
+	if err == nil || !strings.Contains(err.Error(), "x.tmpl") {
+		t.Fatalf("expected StrictSuffix error naming x.tmpl, got %v", err)
+	}
+}
+
+func TestCopyMergesExistingFiles(t *testing.T) {
+	source := fstest.MapFS{
+		"config.json.jinja": {
+			Data: []byte(`{"name": "{{ name }}", "version": 2}`),
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	existing, err := writer.CreateFile("config.json", 0o644)
+	if err != nil {
+		t.Fatalf("prepare destination file: %v", err)
+	}
+	if _, err := existing.Write([]byte(`{"custom": true, "version": 1}`)); err != nil {
+		t.Fatalf("write original: %v", err)
+	}
+	existing.Close()
+
+	mergeJSON := func(current, rendered []byte) ([]byte, error) {
+		merged := map[string]interface{}{}
+		if err := json.Unmarshal(current, &merged); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(rendered, &merged); err != nil {
+			return nil, err
+		}
+		return json.Marshal(merged)
+	}
+
+	opts := renderfs.Options{
+		Context:    pongo2.Context{"name": "demo"},
+		OnConflict: renderfs.Fail,
+		MergeFuncs: map[string]renderfs.MergeFunc{"*.json": mergeJSON},
+	}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	want := `{"custom":true,"name":"demo","version":2}`
+	if got := string(writer.Contents()["config.json"]); got != want {
+		t.Fatalf("unexpected merged content: %s", got)
+	}
+}
+
+func TestAnalyzeSourceGroupsVariablesByFile(t *testing.T) {
+	source := fstest.MapFS{
+		".renderfs-ignore": {
+			Data: []byte("skipped.txt\n"),
+		},
+		"README.md.jinja": {
+			Data: []byte("# {{ project_name }}\n{% if params.use_docker %}docker{% endif %}\n"),
+		},
+		"src/{{ params.app_name }}/main.go.tmpl": {
+			Data: []byte("package {{ params.app_name }}\n// {{ author|upper }}\n"),
+		},
+		"static.txt": {
+			Data: []byte("no variables here"),
+		},
+		"stamp.txt": {
+			Data: []byte("{{ now|date:\"2006\" }} {{ uuid() }} {{ hash('static.txt') }} {{ relpath('static.txt') }}\n"),
+		},
+		"skipped.txt": {
+			Data: []byte("{{ ignored }}"),
+		},
+	}
+
+	got, err := renderfs.AnalyzeSource(source, renderfs.Options{})
+	if err != nil {
+		t.Fatalf("AnalyzeSource failed: %v", err)
+	}
+
+	want := map[string][]string{
+		"README.md.jinja":                        {"params.use_docker", "project_name"},
+		"src/{{ params.app_name }}":              {"params.app_name"},
+		"src/{{ params.app_name }}/main.go.tmpl": {"author", "params.app_name"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected analysis:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestCopyEnforcesMaxRenderedSize(t *testing.T) {
+	source := fstest.MapFS{
+		"big.txt": {
+			Data: []byte("{% for i in items %}0123456789{% endfor %}"),
+		},
+	}
+	context := pongo2.Context{"items": make([]int, 100)}
+
+	writer := writers.NewMemoryWriter()
+	err := renderfs.Copy(source, writer, renderfs.Options{Context: context, MaxRenderedSize: 64})
+	if err == nil || !strings.Contains(err.Error(), "exceeding limit") {
+		t.Fatalf("expected size limit error, got %v", err)
+	}
+	if _, ok := writer.Contents()["big.txt"]; ok {
+		t.Fatalf("oversized file should not be written")
+	}
+
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: context, MaxRenderedSize: 1000}); err != nil {
+		t.Fatalf("Copy within limit failed: %v", err)
+	}
+}
+
+func init() {
+	pongo2.RegisterFilter("renderfs_test_sleep", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		time.Sleep(time.Duration(param.Integer()) * time.Millisecond)
+		return in, nil
+	})
+}
+
+func TestCopyRenderTimeout(t *testing.T) {
+	source := fstest.MapFS{
+		"slow.txt": {
+			Data: []byte("{{ name|renderfs_test_sleep:500 }}"),
+		},
+	}
+	opts := renderfs.Options{
+		Context:       pongo2.Context{"name": "demo"},
+		RenderTimeout: 20 * time.Millisecond,
+	}
+
+	start := time.Now()
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Fatalf("expected Copy to return promptly, took %v", elapsed)
+	}
+
+	opts.RenderTimeout = 5 * time.Second
+	fast := fstest.MapFS{
+		"fast.txt": {
+			Data: []byte("{{ name|renderfs_test_sleep:1 }}"),
+		},
+	}
+	if err := renderfs.Copy(fast, writers.NewMemoryWriter(), opts); err != nil {
+		t.Fatalf("Copy within timeout failed: %v", err)
+	}
+}
+
+func TestCopyIgnoresRenderedPaths(t *testing.T) {
+	source := fstest.MapFS{
+		"{{ scratch }}": {
+			Mode: fs.ModeDir | 0o755,
+		},
+		"{{ scratch }}/notes.txt": {
+			Data: []byte("scratch notes"),
+		},
+		"report.{{ ext }}.jinja": {
+			Data: []byte("draft"),
+		},
+		"keep.txt": {
+			Data: []byte("kept"),
+		},
+	}
+	opts := renderfs.Options{
+		Context:                pongo2.Context{"scratch": "cache", "ext": "tmp"},
+		IgnoreRenderedPatterns: []string{"*.tmp", "cache/"},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	contents := writer.Contents()
+	if len(contents) != 1 || string(contents["keep.txt"]) != "kept" {
+		t.Fatalf("expected only keep.txt, got %v", contents)
+	}
+	if _, ok := writer.DirMode("cache"); ok {
+		t.Fatalf("expected rendered cache directory to be skipped")
+	}
+}
+
+func TestCopyDropsKeepMarker(t *testing.T) {
+	source := fstest.MapFS{
+		"logs": {
+			Mode: fs.ModeDir | 0o750,
+		},
+		"logs/.gitkeep": {},
+		"cache/.keep":   {},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{DropKeepMarker: true}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	if mode, ok := writer.DirMode("logs"); !ok || mode != 0o750 {
+		t.Fatalf("expected logs directory with mode 750, got %v (ok=%v)", mode, ok)
+	}
+	contents := writer.Contents()
+	if _, ok := contents["logs/.gitkeep"]; ok {
+		t.Fatalf("expected .gitkeep to be dropped")
+	}
+	if _, ok := contents["cache/.keep"]; !ok {
+		t.Fatalf("expected non-marker .keep file to be copied")
+	}
+
+	custom := writers.NewMemoryWriter()
+	opts := renderfs.Options{KeepMarker: ".keep", DropKeepMarker: true}
+	if err := renderfs.Copy(source, custom, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if _, ok := custom.DirMode("cache"); !ok {
+		t.Fatalf("expected cache directory to exist")
+	}
+	if _, ok := custom.Contents()["cache/.keep"]; ok {
+		t.Fatalf("expected custom marker to be dropped")
+	}
+}
+
+func TestCopySkipsFilesUnchangedSincePriorManifest(t *testing.T) {
+	source := fstest.MapFS{
+		"stable.txt.jinja": {
+			Data: []byte("version {{ stable }}\n"),
+		},
+		"moving.txt.jinja": {
+			Data: []byte("version {{ moving }}\n"),
+		},
+	}
+
+	var manifest strings.Builder
+	writer := writers.NewMemoryWriter()
+	first := renderfs.Options{
+		Context:        pongo2.Context{"stable": 1, "moving": 1},
+		ManifestWriter: &manifest,
+	}
+	if _, err := renderfs.CopyWithResult(source, writer, first); err != nil {
+		t.Fatalf("first Copy failed: %v", err)
+	}
+
+	var next strings.Builder
+	second := renderfs.Options{
+		Context:        pongo2.Context{"stable": 1, "moving": 2},
+		PriorManifest:  strings.NewReader(manifest.String()),
+		ManifestWriter: &next,
+	}
+	result, err := renderfs.CopyWithResult(source, writer, second)
+	if err != nil {
+		t.Fatalf("second Copy failed: %v", err)
+	}
+	if result.FilesWritten != 1 || result.SkippedUnchanged != 1 {
+		t.Fatalf("expected 1 written and 1 skipped, got %+v", result)
+	}
+	if got := string(writer.Contents()["moving.txt"]); got != "version 2\n" {
+		t.Fatalf("unexpected moving.txt content: %q", got)
+	}
+	if !strings.Contains(next.String(), "  stable.txt\n") {
+		t.Fatalf("expected skipped file to remain in the new manifest:\n%s", next.String())
+	}
+}
+
+func TestCopyIncludesFromSource(t *testing.T) {
+	source := fstest.MapFS{
+		"partials/header.txt": {
+			Data: []byte("== {{ title }} =="),
+		},
+		"page.txt": {
+			Data: []byte("{% include \"partials/header.txt\" %}\nbody\n"),
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: pongo2.Context{"title": "Docs"}}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["page.txt"]); got != "== Docs ==\nbody\n" {
+		t.Fatalf("unexpected page content: %q", got)
+	}
+}
+
+func TestCopyChecksVariablesInIncludedTemplates(t *testing.T) {
+	snippets := fstest.MapFS{
+		"base.html": {Data: []byte("<title>{{ title }}</title>{% block body %}{% endblock %}<footer>{{ footer }}</footer>")},
+	}
+	source := fstest.MapFS{
+		"page.html": {Data: []byte(`{% extends "base.html" %}{% block body %}{{ body }}{% endblock %}`)},
+	}
+	opts := renderfs.Options{
+		Context:   pongo2.Context{"title": "Home", "body": "Hello"},
+		SnippetFS: snippets,
+	}
+
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "missing context value for 'footer' in base.html") {
+		t.Fatalf("expected missing variable in base template, got %v", err)
+	}
+
+	opts.Context["footer"] = "Bye"
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["page.html"]); got != "<title>Home</title>Hello<footer>Bye</footer>" {
+		t.Fatalf("unexpected page content: %q", got)
+	}
+}
+
+func TestCopyDetectsIncludeCycles(t *testing.T) {
+	source := fstest.MapFS{
+		"a.txt": {
+			Data: []byte("A {% include \"b.txt\" %}"),
+		},
+		"b.txt": {
+			Data: []byte("B {% include \"a.txt\" %}"),
+		},
+	}
+
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{})
+	if err == nil || !strings.Contains(err.Error(), "include cycle: b.txt -> a.txt -> b.txt") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+}
+
+func TestCopyDirModeFunc(t *testing.T) {
+	source := fstest.MapFS{
+		"shared": {
+			Mode: fs.ModeDir | 0o700,
+		},
+		"shared/{{ team }}": {
+			Mode: fs.ModeDir | 0o755,
+		},
+	}
+
+	var seen []string
+	opts := renderfs.Options{
+		Context: pongo2.Context{"team": "ops"},
+		DirModeFunc: func(rel string, info fs.FileInfo) fs.FileMode {
+			seen = append(seen, rel)
+			return 0o775
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	for _, dir := range []string{"shared", "shared/ops"} {
+		if mode, ok := writer.DirMode(dir); !ok || mode != 0o775 {
+			t.Fatalf("expected %s mode 775, got %v (ok=%v)", dir, mode, ok)
+		}
+	}
+	if want := []string{"shared", "shared/{{ team }}"}; !reflect.DeepEqual(seen, want) {
+		t.Fatalf("expected DirModeFunc to receive source paths %v, got %v", want, seen)
+	}
+}
+
+func TestCopyKeepsRestrictiveParentDirMode(t *testing.T) {
+	source := fstest.MapFS{
+		"secrets":                {Mode: fs.ModeDir | 0o700},
+		"secrets/keys":           {Mode: fs.ModeDir | 0o700},
+		"secrets/keys/id.txt":    {Data: []byte("key")},
+		"secrets/{{ env }}.yaml": {Data: []byte("env: {{ env }}")},
+	}
+	opts := renderfs.Options{Context: pongo2.Context{"env": "prod"}}
+
+	memory := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, memory, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	for _, dir := range []string{"secrets", "secrets/keys"} {
+		if mode, ok := memory.DirMode(dir); !ok || mode != 0o700 {
+			t.Fatalf("expected %s mode 700, got %v (ok=%v)", dir, mode, ok)
+		}
+	}
+
+	dest := t.TempDir()
+	writer, err := writers.NewOSWriter(dest)
+	if err != nil {
+		t.Fatalf("NewOSWriter failed: %v", err)
+	}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	for _, dir := range []string{"secrets", "secrets/keys"} {
+		info, err := os.Stat(filepath.Join(dest, dir))
+		if err != nil {
+			t.Fatalf("stat %s: %v", dir, err)
+		}
+		if mode := info.Mode().Perm(); mode != 0o700 {
+			t.Fatalf("expected %s mode 700 on disk, got %v", dir, mode)
+		}
+	}
+}
+
+// opRecorder logs the directory and file creations passed to a MemoryWriter.
+type opRecorder struct {
+	*writers.MemoryWriter
+	ops []string
+}
+
+func (r *opRecorder) MkdirAll(p string, perm fs.FileMode) error {
+	r.ops = append(r.ops, "mkdir "+p)
+	return r.MemoryWriter.MkdirAll(p, perm)
+}
+
+func (r *opRecorder) CreateFile(p string, perm fs.FileMode) (io.WriteCloser, error) {
+	r.ops = append(r.ops, "create "+p)
+	return r.MemoryWriter.CreateFile(p, perm)
+}
+
+func TestCopyCreatesDirectoriesBeforeFiles(t *testing.T) {
+	source := fstest.MapFS{
+		"a.txt":           {Data: []byte("a")},
+		"outer":           {Mode: fs.ModeDir | 0o750},
+		"outer/b.txt":     {Data: []byte("b")},
+		"outer/inner":     {Mode: fs.ModeDir | 0o700},
+		"outer/inner/c":   {Data: []byte("c")},
+		"outer/z":         {Mode: fs.ModeDir | 0o711},
+		"outer/z/d.txt":   {Data: []byte("d")},
+		"outer/z/e/f.txt": {Data: []byte("f")},
+	}
+
+	recorder := &opRecorder{MemoryWriter: writers.NewMemoryWriter()}
+	if err := renderfs.Copy(source, recorder, renderfs.Options{}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	want := []string{
+		"mkdir outer", "mkdir outer/inner", "mkdir outer/z", "mkdir outer/z/e",
+		"create a.txt", "create outer/b.txt", "create outer/inner/c", "create outer/z/d.txt", "create outer/z/e/f.txt",
+	}
+	if !reflect.DeepEqual(recorder.ops, want) {
+		t.Fatalf("unexpected operation order:\n got %v\nwant %v", recorder.ops, want)
+	}
+	for dir, mode := range map[string]fs.FileMode{"outer": 0o750, "outer/inner": 0o700, "outer/z": 0o711} {
+		if got, ok := recorder.DirMode(dir); !ok || got != mode {
+			t.Fatalf("expected %s mode %v, got %v (ok=%v)", dir, mode, got, ok)
+		}
+	}
+}
+
+func TestCopyMaxDepth(t *testing.T) {
+	source := fstest.MapFS{
+		"README.md":            {Data: []byte("top")},
+		"vendor/lib.go":        {Data: []byte("lib")},
+		"vendor/deep/mod.go":   {Data: []byte("mod")},
+		"vendor/deep/x/pkg.go": {Data: []byte("pkg")},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{MaxDepth: 1}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	contents := writer.Contents()
+	if len(contents) != 1 || string(contents["README.md"]) != "top" {
+		t.Fatalf("expected only top-level files, got %v", contents)
+	}
+	if _, ok := writer.DirMode("vendor"); !ok {
+		t.Fatalf("expected top-level directory to be created")
+	}
+	if _, ok := writer.DirMode("vendor/deep"); ok {
+		t.Fatalf("expected nested directory to be skipped")
+	}
+}
+
+func TestCopyFlatten(t *testing.T) {
+	source := fstest.MapFS{
+		"a/x.txt":    {Data: []byte("x")},
+		"b/c/y.txt":  {Data: []byte("{{ name }}")},
+		"b/.gitkeep": {},
+	}
+
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{Context: pongo2.Context{"name": "y"}, Flatten: true}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	want := map[string][]byte{"x.txt": []byte("x"), "y.txt": []byte("y")}
+	if got := writer.Contents(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected files in the root, got %v", got)
+	}
+	if _, ok := writer.DirMode("a"); ok {
+		t.Fatalf("expected no directories to be created")
+	}
+
+	source["b/x.txt"] = &fstest.MapFile{Data: []byte("other")}
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "a/x.txt and b/x.txt both flatten to x.txt") {
+		t.Fatalf("expected flatten collision, got %v", err)
+	}
+}
+
+func TestCopyContentDecoders(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte("name: {{ name }}\n")); err != nil {
+		t.Fatalf("gzip write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+
+	source := fstest.MapFS{
+		"config.yaml.gz.tmpl": {Data: compressed.Bytes()},
+		"notes.txt":           {Data: []byte("{{ name }}")},
+	}
+	opts := renderfs.Options{
+		Context: pongo2.Context{"name": "demo"},
+		ContentDecoders: map[string]func(io.Reader) (io.Reader, error){
+			".gz": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	want := map[string][]byte{"config.yaml": []byte("name: demo\n"), "notes.txt": []byte("demo")}
+	if got := writer.Contents(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected contents %q", got)
+	}
+
+	source["broken.gz"] = &fstest.MapFile{Data: []byte("not gzip")}
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "decode broken.gz") {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+}
+
+func TestCopyValidateStructured(t *testing.T) {
+	source := fstest.MapFS{
+		"package.json": {Data: []byte(`{
+  "name": "{{ name }}"{% if private %}
+  "private": true{% endif %}
+}
+`)},
+		"config.yml": {Data: []byte("name: {{ name }}\n{% if debug %}  level: debug\n{% endif %}")},
+	}
+	opts := renderfs.Options{
+		Context:            pongo2.Context{"name": "demo", "private": false, "debug": false},
+		ValidateStructured: true,
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	opts.Context = pongo2.Context{"name": "demo", "private": true, "debug": false}
+	writer = writers.NewMemoryWriter()
+	err := renderfs.Copy(source, writer, opts)
+	if err == nil || !strings.Contains(err.Error(), "rendered package.json is not valid JSON") {
+		t.Fatalf("expected invalid JSON error, got %v", err)
+	}
+	if _, ok := writer.Contents()["package.json"]; ok {
+		t.Fatalf("expected invalid JSON not to be written")
+	}
+
+	opts.Context = pongo2.Context{"name": "demo", "private": false, "debug": true}
+	err = renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "rendered config.yml is not valid YAML") {
+		t.Fatalf("expected invalid YAML error, got %v", err)
+	}
+}
+
+func TestCopyPrecompileTemplatesRejectsUnknownFilters(t *testing.T) {
+	source := fstest.MapFS{
+		"a.txt": {Data: []byte("Hello {{ name }}\n")},
+		"z.txt": {Data: []byte("Hello {{ name|shout }}\n")},
+	}
+	opts := renderfs.Options{
+		Context:             pongo2.Context{"name": "demo"},
+		PrecompileTemplates: true,
+	}
+
+	writer := writers.NewMemoryWriter()
+	err := renderfs.Copy(source, writer, opts)
+	if err == nil || !strings.Contains(err.Error(), "z.txt:1:") || !strings.Contains(err.Error(), "Filter 'shout' does not exist") {
+		t.Fatalf("expected unknown filter error for z.txt, got %v", err)
+	}
+	if files := writer.Contents(); len(files) != 0 {
+		t.Fatalf("expected nothing written, got %v", files)
+	}
+}
+
+func TestCopyRedirects(t *testing.T) {
+	source := fstest.MapFS{
+		"templates/ci.yml":   {Data: []byte("name: {{ name }}\n")},
+		"templates/other.md": {Data: []byte("other\n")},
+	}
+	opts := renderfs.Options{
+		Context:   pongo2.Context{"name": "demo"},
+		Redirects: map[string]string{"templates/ci.yml": ".github/workflows/ci.yml"},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	files := writer.Contents()
+	if got := string(files[".github/workflows/ci.yml"]); got != "name: demo\n" {
+		t.Fatalf("unexpected redirected content %q", got)
+	}
+	if _, ok := files["templates/ci.yml"]; ok {
+		t.Fatalf("expected templates/ci.yml not to be written")
+	}
+	if _, ok := files["templates/other.md"]; !ok {
+		t.Fatalf("expected templates/other.md to be written")
+	}
+
+	opts.Redirects = map[string]string{"templates/ci.yml": "../ci.yml"}
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "escapes destination") {
+		t.Fatalf("expected escape error, got %v", err)
+	}
+}
+
+func TestCopyDirectoryCondition(t *testing.T) {
+	source := fstest.MapFS{
+		"examples/.renderfs-if":   {Data: []byte("{% if with_examples %}yes{% endif %}\n")},
+		"examples/basic.go":       {Data: []byte("package {{ name }}\n")},
+		"examples/nested/deep.go": {Data: []byte("package nested\n")},
+		"main.go":                 {Data: []byte("package {{ name }}\n")},
+	}
+	opts := renderfs.Options{
+		Context:             pongo2.Context{"name": "demo", "with_examples": false},
+		DirectoryConditions: true,
+	}
+
+	var skipped []string
+	opts.OnSkip = func(rel string, reason renderfs.SkipReason) {
+		if reason == renderfs.SkipCondition {
+			skipped = append(skipped, rel)
+		}
+	}
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if _, err := writer.FS().(fs.StatFS).Stat("examples"); err != nil {
+		t.Fatalf("expected examples directory to be kept: %v", err)
+	}
+	if files := writer.Contents(); len(files) != 1 || files["main.go"] == nil {
+		t.Fatalf("expected only main.go, got %v", files)
+	}
+	if want := []string{"examples/basic.go", "examples/nested"}; !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("expected skipped %v, got %v", want, skipped)
+	}
+
+	opts.Context["with_examples"] = true
+	opts.OnSkip = nil
+	writer = writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	files := writer.Contents()
+	for _, name := range []string{"examples/basic.go", "examples/nested/deep.go", "main.go"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("expected %s to be written, got %v", name, files)
+		}
+	}
+	if _, ok := files["examples/.renderfs-if"]; ok {
+		t.Fatalf("expected condition file not to be written")
+	}
+
+	// Without DirectoryConditions, the condition file is an ordinary file.
+	opts.Context["with_examples"] = false
+	opts.DirectoryConditions = false
+	writer = writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	files = writer.Contents()
+	if _, ok := files["examples/basic.go"]; !ok {
+		t.Fatalf("expected examples to be copied without DirectoryConditions, got %v", files)
+	}
+	if got := string(files["examples/.renderfs-if"]); got != "\n" {
+		t.Fatalf("expected condition file to be rendered as content, got %q", got)
+	}
+}
+
+func TestCopyExposeSource(t *testing.T) {
+	source := fstest.MapFS{
+		"out.txt": {Data: []byte("Hello {{ name }}\n# from: {{ __source__|slice:\":16\" }}\n")},
+	}
+	opts := renderfs.Options{
+		Context:      pongo2.Context{"name": "demo"},
+		ExposeSource: true,
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	want := "Hello demo\n# from: Hello {{ name }}\n"
+	if got := string(writer.Contents()["out.txt"]); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	opts.ExposeSource = false
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "__source__") {
+		t.Fatalf("expected missing __source__ error without ExposeSource, got %v", err)
+	}
+}
+
+func TestCopyVerbatimPatterns(t *testing.T) {
+	source := fstest.MapFS{
+		"main.go.tmpl":                      {Data: []byte("package {{ name }}\n")},
+		"vendor/lib/{{ raw }}.go":           {Data: []byte("// {{ undefined }} {% broken\n")},
+		"vendor/lib/README.md.tmpl":         {Data: []byte("{{ also_undefined }}\n")},
+		"vendor/tools/run.sh":               {Data: []byte("#!/bin/sh\necho {{ x }}\n"), Mode: 0o755},
+		"vendor/tools/data/{{ name }}.json": {Data: []byte(`{"a": "{% raw %}"}`)},
+	}
+	opts := renderfs.Options{
+		Context:             pongo2.Context{"name": "demo"},
+		VerbatimPatterns:    []string{"vendor/"},
+		PrecompileTemplates: true,
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	files := writer.Contents()
+	if got := string(files["main.go"]); got != "package demo\n" {
+		t.Fatalf("expected main.go to render, got %q", got)
+	}
+	for name, file := range source {
+		if !strings.HasPrefix(name, "vendor/") {
+			continue
+		}
+		if got, ok := files[name]; !ok || !bytes.Equal(got, file.Data) {
+			t.Fatalf("expected %s to be copied verbatim, got %q (present=%v)", name, got, ok)
+		}
+	}
+	if mode, _ := writer.FileMode("vendor/tools/run.sh"); mode != 0o755 {
+		t.Fatalf("expected mode 0755, got %v", mode)
+	}
+
+	conflicts := map[string]bool{}
+	opts.OnConflict = renderfs.Skip
+	opts.OnSkip = func(rel string, reason renderfs.SkipReason) {
+		if reason == renderfs.SkipConflict {
+			conflicts[rel] = true
+		}
+	}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("second Copy failed: %v", err)
+	}
+	if !conflicts["vendor/tools/run.sh"] {
+		t.Fatalf("expected existing verbatim file to go through conflict handling, got %v", conflicts)
+	}
+}
+
+func TestCopyReportsTemplateCompileErrors(t *testing.T) {
+	source := fstest.MapFS{
+		"docs/guide.md": {Data: []byte("# Guide\nWelcome to {{ name|shout }} today\n")},
+	}
+	opts := renderfs.Options{Context: pongo2.Context{"name": "demo"}}
+
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	var compileErr *renderfs.TemplateCompileError
+	if !errors.As(err, &compileErr) {
+		t.Fatalf("expected a TemplateCompileError, got %v", err)
+	}
+	if compileErr.Path != "docs/guide.md" || compileErr.Line != 2 {
+		t.Fatalf("unexpected position %s line %d", compileErr.Path, compileErr.Line)
+	}
+	if !strings.Contains(compileErr.Snippet, "name|shout") {
+		t.Fatalf("expected snippet around the filter, got %q", compileErr.Snippet)
+	}
+	for _, want := range []string{"docs/guide.md:2:", "Filter 'shout' does not exist", compileErr.Snippet} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to mention %q, got %v", want, err)
+		}
+	}
+	if n := strings.Count(err.Error(), "docs/guide.md"); n != 1 {
+		t.Fatalf("expected the path once, got %v", err)
+	}
+
+	// Positions refer to the source file, counting a stripped mode directive
+	// and front matter.
+	source = fstest.MapFS{
+		"a.txt": {Data: []byte("{# renderfs:mode=0644 #}\n---\ntitle: x\n---\nfirst\nsecond {{ name|shout }}\n")},
+	}
+	for _, precompile := range []bool{false, true} {
+		opts := renderfs.Options{Context: pongo2.Context{"name": "demo"}, FrontMatter: true, PrecompileTemplates: precompile}
+		err = renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+		if !errors.As(err, &compileErr) || compileErr.Path != "a.txt" || compileErr.Line != 6 {
+			t.Fatalf("precompile=%v: expected a.txt line 6, got %v", precompile, err)
+		}
+		if !strings.HasPrefix(err.Error(), "renderfs: a.txt:6:") {
+			t.Fatalf("precompile=%v: unexpected message %v", precompile, err)
+		}
+	}
+}
+
+func TestCopyModeDirective(t *testing.T) {
+	source := fstest.MapFS{
+		"bin/{{ name }}.sh": {Data: []byte("{# renderfs:mode=0755 #}\n#!/bin/sh\necho {{ name }}\n"), Mode: 0o644},
+		"README.md":         {Data: []byte("{{ name }}\n"), Mode: 0o644},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: pongo2.Context{"name": "deploy"}}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["bin/deploy.sh"]); got != "#!/bin/sh\necho deploy\n" {
+		t.Fatalf("expected directive to be stripped, got %q", got)
+	}
+	if mode, ok := writer.FileMode("bin/deploy.sh"); !ok || mode != 0o755 {
+		t.Fatalf("expected script mode 755, got %v (ok=%v)", mode, ok)
+	}
+	if mode, ok := writer.FileMode("README.md"); !ok || mode != 0o644 {
+		t.Fatalf("expected README mode 644, got %v (ok=%v)", mode, ok)
+	}
+
+	source["bad.sh"] = &fstest.MapFile{Data: []byte("{# renderfs:mode=rwx #}\n")}
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{Context: pongo2.Context{"name": "deploy"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid mode directive") {
+		t.Fatalf("expected invalid directive error, got %v", err)
+	}
+}
+
+func TestCopyAppendGlobs(t *testing.T) {
+	source := fstest.MapFS{
+		"fragments/a.log": {Data: []byte("first {{ n }}\n")},
+		"fragments/b.log": {Data: []byte("second\n")},
+		"README.md":       {Data: []byte("readme")},
+	}
+	opts := renderfs.Options{
+		Context:     pongo2.Context{"n": 1},
+		OnConflict:  renderfs.Fail,
+		AppendGlobs: []string{"*.log"},
+		PathMapper: func(rel string, isDir bool) (string, bool, error) {
+			if !isDir && strings.HasSuffix(rel, ".log") {
+				return "build.log", false, nil
+			}
+			return rel, false, nil
+		},
+	}
+
+	memory := writers.NewMemoryWriter()
+	handle, err := memory.CreateFile("build.log", 0o644)
+	if err != nil {
+		t.Fatalf("CreateFile failed: %v", err)
+	}
+	handle.Write([]byte("header\n"))
+	handle.Close()
+	if err := renderfs.Copy(source, memory, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(memory.Contents()["build.log"]); got != "header\nfirst 1\nsecond\n" {
+		t.Fatalf("unexpected appended content %q", got)
+	}
+
+	dest := t.TempDir()
+	writer, err := writers.NewOSWriter(dest)
+	if err != nil {
+		t.Fatalf("NewOSWriter failed: %v", err)
+	}
+	opts.OnConflict = renderfs.Overwrite
+	for range 2 {
+		if err := renderfs.Copy(source, writer, opts); err != nil {
+			t.Fatalf("Copy failed: %v", err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "build.log"))
+	if err != nil {
+		t.Fatalf("read build.log: %v", err)
+	}
+	if got := string(data); got != "first 1\nsecond\nfirst 1\nsecond\n" {
+		t.Fatalf("unexpected appended content on disk %q", got)
+	}
+
+	// A staged copy starts from the destination's content.
+	opts.Transactional = true
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("transactional Copy failed: %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(dest, "build.log"))
+	if err != nil {
+		t.Fatalf("read build.log: %v", err)
+	}
+	if got := string(data); got != strings.Repeat("first 1\nsecond\n", 3) {
+		t.Fatalf("unexpected staged appended content %q", got)
+	}
+	opts.Transactional = false
+
+	recorder := &chunkRecorder{totals: map[string]int{}}
+	err = renderfs.Copy(source, recorder, opts)
+	if err == nil || !strings.Contains(err.Error(), "does not support appending") {
+		t.Fatalf("expected unsupported append error, got %v", err)
+	}
+}
+
+func TestCopyOnSkip(t *testing.T) {
+	source := fstest.MapFS{
+		"kept.txt":                             {Data: []byte("kept")},
+		"ignored.txt":                          {Data: []byte("ignored")},
+		"{% if flag %}optional.txt{% endif %}": {Data: []byte("optional")},
+		"exists.txt":                           {Data: []byte("new")},
+		"filtered.txt":                         {Data: []byte("filtered")},
+		"blank.txt":                            {Data: []byte("  {{ empty }}  ")},
+		"once.txt":                             {Data: []byte("once")},
+		"a/b/deep.txt":                         {Data: []byte("deep")},
+		"big.bin":                              {Data: bytes.Repeat([]byte("x"), 128)},
+	}
+
+	writer := writers.NewMemoryWriter()
+	for _, name := range []string{"exists.txt", "once.txt"} {
+		handle, err := writer.CreateFile(name, 0o644)
+		if err != nil {
+			t.Fatalf("CreateFile failed: %v", err)
+		}
+		handle.Close()
+	}
+
+	got := make(map[string]renderfs.SkipReason)
+	opts := renderfs.Options{
+		Context:           pongo2.Context{"flag": false, "empty": ""},
+		IgnorePatterns:    []string{"ignored.txt"},
+		OnConflict:        renderfs.Skip,
+		SkipEmptyFiles:    true,
+		OnceGlobs:         []string{"once.txt"},
+		MaxDepth:          2,
+		MaxSourceFileSize: 64,
+		ExposeFileList:    true,
+		PathMapper: func(rel string, isDir bool) (string, bool, error) {
+			return rel, rel == "filtered.txt", nil
+		},
+		OnSkip: func(rel string, reason renderfs.SkipReason) {
+			if prev, seen := got[rel]; seen {
+				t.Errorf("%s reported twice (%v, %v)", rel, prev, reason)
+			}
+			got[rel] = reason
+		},
+	}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	want := map[string]renderfs.SkipReason{
+		"ignored.txt":                          renderfs.SkipIgnored,
+		"{% if flag %}optional.txt{% endif %}": renderfs.SkipEmptyPath,
+		"exists.txt":                           renderfs.SkipConflict,
+		"filtered.txt":                         renderfs.SkipFiltered,
+		"blank.txt":                            renderfs.SkipEmptyContent,
+		"once.txt":                             renderfs.SkipOnce,
+		"a/b/deep.txt":                         renderfs.SkipMaxDepth,
+		"big.bin":                              renderfs.SkipOversized,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected skips:\n got %v\nwant %v", got, want)
+	}
+	if s := renderfs.SkipEmptyContent.String(); s != "empty content" {
+		t.Fatalf("unexpected reason string %q", s)
+	}
+}
+
+func TestCopyTranslations(t *testing.T) {
+	source := fstest.MapFS{
+		"README.md": {Data: []byte("{{ t('welcome') }}, {{ name }}! {{ t('docs') }}")},
+	}
+	translations := map[string]map[string]string{
+		"en": {"welcome": "Welcome", "docs": "See the docs."},
+		"de": {"welcome": "Willkommen"},
+	}
+
+	for locale, want := range map[string]string{
+		"en": "Welcome, Ada! See the docs.",
+		"de": "Willkommen, Ada! See the docs.",
+	} {
+		writer := writers.NewMemoryWriter()
+		opts := renderfs.Options{
+			Context:        pongo2.Context{"name": "Ada"},
+			Translations:   translations,
+			Locale:         locale,
+			FallbackLocale: "en",
+		}
+		if err := renderfs.Copy(source, writer, opts); err != nil {
+			t.Fatalf("Copy (%s) failed: %v", locale, err)
+		}
+		if got := string(writer.Contents()["README.md"]); got != want {
+			t.Fatalf("locale %s: expected %q, got %q", locale, want, got)
+		}
+	}
+
+	opts := renderfs.Options{Context: pongo2.Context{"name": "Ada"}, Translations: translations, Locale: "de"}
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), `no "de" translation for "docs"`) {
+		t.Fatalf("expected missing translation error, got %v", err)
+	}
+}
+
+func TestCopyDirModeOverrides(t *testing.T) {
+	source := fstest.MapFS{
+		"scripts":                {Mode: fs.ModeDir | 0o555},
+		"scripts/setup.sh":       {Data: []byte("#!/bin/sh\n")},
+		"{{ name }}/scripts":     {Mode: fs.ModeDir | 0o700},
+		"{{ name }}/scripts/run": {Data: []byte("run")},
+		"docs":                   {Mode: fs.ModeDir | 0o750},
+	}
+	opts := renderfs.Options{
+		Context:          pongo2.Context{"name": "tool"},
+		DirModeFunc:      func(string, fs.FileInfo) fs.FileMode { return 0o700 },
+		DirModeOverrides: map[string]fs.FileMode{"scripts": 0o755},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	for dir, want := range map[string]fs.FileMode{"scripts": 0o755, "tool/scripts": 0o755, "tool": 0o700, "docs": 0o700} {
+		if mode, ok := writer.DirMode(dir); !ok || mode != want {
+			t.Fatalf("expected %s mode %v, got %v (ok=%v)", dir, want, mode, ok)
+		}
+	}
+}
+
+func TestCopyMaxNameLength(t *testing.T) {
+	source := fstest.MapFS{
+		"docs/{{ title }}.md": {Data: []byte("body")},
+	}
+	long := strings.Repeat("a", 300)
+
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{Context: pongo2.Context{"title": long}})
+	if err == nil || !strings.Contains(err.Error(), "rendered path for docs/{{ title }}.md has a 303-byte name") {
+		t.Fatalf("expected name length error, got %v", err)
+	}
+
+	opts := renderfs.Options{Context: pongo2.Context{"title": "intro"}, MaxNameLength: 5}
+	err = renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), `"intro.md", exceeding the limit of 5`) {
+		t.Fatalf("expected configured limit to apply, got %v", err)
+	}
+
+	writer := writers.NewMemoryWriter()
+	opts = renderfs.Options{Context: pongo2.Context{"title": long}, MaxNameLength: -1}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed with the check disabled: %v", err)
+	}
+	if _, ok := writer.Contents()["docs/"+long+".md"]; !ok {
+		t.Fatalf("expected long name to be written when the check is disabled")
+	}
+}
+
+func TestCopyUsesGitignore(t *testing.T) {
+	source := fstest.MapFS{
+		".gitignore": {
+			Data: []byte("# build output\ndist/\n"),
+		},
+		"dist/app.js": {
+			Data: []byte("built"),
+		},
+		"src/app.js": {
+			Data: []byte("source"),
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{UseGitignore: true}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	contents := writer.Contents()
+	if _, ok := contents["dist/app.js"]; ok {
+		t.Fatalf("expected dist/ to be ignored")
+	}
+	if _, ok := writer.DirMode("dist"); ok {
+		t.Fatalf("expected the ignored dist directory itself not to be created")
+	}
+	if _, ok := contents[".gitignore"]; ok {
+		t.Fatalf("expected .gitignore to be excluded when used for rules")
+	}
+	if _, ok := contents["src/app.js"]; !ok {
+		t.Fatalf("expected src/app.js to be copied")
+	}
+
+	plain := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, plain, renderfs.Options{}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if _, ok := plain.Contents()["dist/app.js"]; !ok {
+		t.Fatalf("expected .gitignore to be ignored without UseGitignore")
+	}
+}
+
+func TestCopyPlainAndCommentOnlyContent(t *testing.T) {
+	source := fstest.MapFS{
+		"plain.txt": {
+			Data: []byte("no { templates } here"),
+		},
+		"comment.txt": {
+			Data: []byte("{# internal note #}kept"),
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	contents := writer.Contents()
+	if got := string(contents["plain.txt"]); got != "no { templates } here" {
+		t.Fatalf("unexpected plain content: %q", got)
+	}
+	if got := string(contents["comment.txt"]); got != "kept" {
+		t.Fatalf("expected comment to be stripped, got %q", got)
+	}
+}
+
+func BenchmarkCopyPlainTree(b *testing.B) {
+	source := fstest.MapFS{}
+	for i := 0; i < 20; i++ {
+		dir := fmt.Sprintf("pkg%d", i)
+		source[dir] = &fstest.MapFile{Mode: fs.ModeDir | 0o755}
+		for j := 0; j < 50; j++ {
+			sub := fmt.Sprintf("%s/mod%d", dir, j)
+			source[sub] = &fstest.MapFile{Mode: fs.ModeDir | 0o755}
+			source[sub+"/file.txt"] = &fstest.MapFile{Data: []byte("x"), Mode: 0o644}
+		}
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCopyPlainFilesWithoutDelimiters(t *testing.T) {
+	source := fstest.MapFS{
+		"main.go":              {Data: []byte("package main\n\nfunc main() { println(\"} {\") }\n")},
+		"notes/{draft}.md":     {Data: []byte("# { not a template }\nitems: {a, b}\n")},
+		"config/settings.json": {Data: []byte(`{"nested": {"key": "value"}}`)},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	contents := writer.Contents()
+	if len(contents) != len(source) {
+		t.Fatalf("expected %d files, got %v", len(source), contents)
+	}
+	for name, file := range source {
+		if got := string(contents[name]); got != string(file.Data) {
+			t.Fatalf("expected %s to be copied unchanged, got %q", name, got)
+		}
+	}
+}
+
+func BenchmarkCopyPlainFiles(b *testing.B) {
+	content := []byte(strings.Repeat("static line with { braces } but no template markers\n", 200))
+	source := fstest.MapFS{}
+	for i := 0; i < 500; i++ {
+		source[fmt.Sprintf("src/file%d.txt", i)] = &fstest.MapFile{Data: content, Mode: 0o644}
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCopyContextChainFallsThrough(t *testing.T) {
+	source := fstest.MapFS{
+		"db.conf.tmpl": {
+			Data: []byte("{{ db.host }}:{{ db.port }} as {{ db.user }} ({{ env }})"),
+		},
+	}
+
+	defaults := pongo2.Context{
+		"env": "dev",
+		"db": pongo2.Context{
+			"host": "localhost",
+			"port": 5432,
+			"user": "postgres",
+		},
+	}
+	overrides := pongo2.Context{
+		"db": map[string]interface{}{"host": "db.internal"},
+	}
+	opts := renderfs.Options{
+		Context:      pongo2.Context{"env": "prod"},
+		ContextChain: []pongo2.Context{overrides, defaults},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["db.conf"]); got != "db.internal:5432 as postgres (prod)" {
+		t.Fatalf("unexpected content: %q", got)
+	}
+	if defaults["db"].(pongo2.Context)["host"] != "localhost" {
+		t.Fatalf("expected chain layers to be left untouched")
+	}
+
+	missing := fstest.MapFS{
+		"x.txt": {
+			Data: []byte("{{ db.password }}"),
+		},
+	}
+	if err := renderfs.Copy(missing, writers.NewMemoryWriter(), opts); err == nil {
+		t.Fatalf("expected missing nested key to fail across all layers")
+	}
+}
+
+func TestCopyRejectsAbsoluteRenderedPaths(t *testing.T) {
+	source := fstest.MapFS{
+		"{{ target }}": {
+			Data: []byte("payload"),
+		},
+	}
+
+	for _, target := range []string{"C:/x", `C:\x`, "c:relative", `\\host\share`, "//host/share", "/etc/passwd", "..", "a/../../b"} {
+		opts := renderfs.Options{Context: pongo2.Context{"target": target}}
+		err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+		if err == nil || !strings.Contains(err.Error(), "escapes destination") {
+			t.Errorf("expected %q to be rejected as escaping, got %v", target, err)
+		}
+	}
+}
+
+func TestCopyPathMapper(t *testing.T) {
+	source := fstest.MapFS{
+		"docs/{{ name }}.md": {
+			Data: []byte("# {{ name }}"),
+		},
+		"docs/drafts/wip.md": {
+			Data: []byte("draft"),
+		},
+		"README.txt": {
+			Data: []byte("readme"),
+		},
+	}
+
+	mapper := func(rel string, isDir bool) (string, bool, error) {
+		if isDir && rel == "docs/drafts" {
+			return "", true, nil
+		}
+		if strings.HasSuffix(rel, ".md") {
+			return strings.TrimSuffix(rel, ".md") + ".html", false, nil
+		}
+		return rel, false, nil
+	}
+	opts := renderfs.Options{
+		Context:    pongo2.Context{"name": "guide"},
+		PathMapper: mapper,
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	contents := writer.Contents()
+	if got := string(contents["docs/guide.html"]); got != "# guide" {
+		t.Fatalf("expected mapped file, got %q", got)
+	}
+	if _, ok := contents["docs/guide.md"]; ok {
+		t.Fatalf("expected unmapped path to be absent")
+	}
+	if _, ok := contents["docs/drafts/wip.html"]; ok {
+		t.Fatalf("expected skipped directory contents to be absent")
+	}
+	if got := string(contents["README.txt"]); got != "readme" {
+		t.Fatalf("expected passthrough file, got %q", got)
+	}
+
+	opts.PathMapper = func(rel string, isDir bool) (string, bool, error) {
+		return "../" + rel, false, nil
+	}
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "escapes destination") {
+		t.Fatalf("expected escaping mapped path to be rejected, got %v", err)
+	}
+}
+
+func TestCopyIncludeIgnoreFile(t *testing.T) {
+	source := fstest.MapFS{
+		".renderfs-ignore": {
+			Data: []byte("ignored.txt\n"),
+		},
+		"ignored.txt": {
+			Data: []byte("ignore me"),
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{IncludeIgnoreFile: true}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	if got := string(writer.Contents()[".renderfs-ignore"]); got != "ignored.txt\n" {
+		t.Fatalf("expected .renderfs-ignore to be copied, got %q", got)
+	}
+	if _, ok := writer.Contents()["ignored.txt"]; ok {
+		t.Fatalf("expected patterns to still apply")
+	}
+}
+
+func TestCopyTransformersByExtension(t *testing.T) {
+	source := fstest.MapFS{
+		"main.go.tmpl": {
+			Data: []byte("package {{ pkg }}\nfunc  main( ) {  }\n"),
+		},
+		"notes.txt": {
+			Data: []byte("func  main( ) {  }"),
+		},
+	}
+
+	opts := renderfs.Options{
+		Context: pongo2.Context{"pkg": "main"},
+		Transformers: map[string]func([]byte) ([]byte, error){
+			".go": format.Source,
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["main.go"]); got != "package main\n\nfunc main() {}\n" {
+		t.Fatalf("expected formatted Go source, got %q", got)
+	}
+	if got := string(writer.Contents()["notes.txt"]); got != "func  main( ) {  }" {
+		t.Fatalf("expected other extensions untouched, got %q", got)
+	}
+
+	errBroken := errors.New("broken")
+	opts.Transformers[".go"] = func([]byte) ([]byte, error) { return nil, errBroken }
+	writer = writers.NewMemoryWriter()
+	err := renderfs.Copy(source, writer, opts)
+	if !errors.Is(err, errBroken) || !strings.Contains(err.Error(), "main.go") {
+		t.Fatalf("expected wrapped transformer error naming the path, got %v", err)
+	}
+	if _, ok := writer.Contents()["main.go"]; ok {
+		t.Fatalf("expected failed transform to leave nothing written")
+	}
+}
+
+func TestCopyStrictSubscripts(t *testing.T) {
+	ctx := pongo2.Context{
+		"items": []string{"a", "b"},
+		"count": 3,
+		"i":     5,
+		"j":     1,
+	}
+
+	// Lenient mode lets the last two through to pongo2, so only strict mode
+	// is exercised for them.
+	cases := []struct {
+		tpl        string
+		modes      []bool
+		want       bool
+		wantOutput string
+	}{
+		{tpl: "{{ missing[j] }}", modes: []bool{false, true}, want: false},
+		{tpl: "{{ items[j] }}", modes: []bool{false, true}, want: true, wantOutput: "b"},
+		{tpl: "{{ items[i] }}", modes: []bool{true}, want: false},
+		{tpl: "{{ count[j] }}", modes: []bool{true}, want: false},
+	}
+
+	for _, tc := range cases {
+		source := fstest.MapFS{"out.txt": {Data: []byte(tc.tpl)}}
+		for _, strict := range tc.modes {
+			want := tc.want
+
+			writer := writers.NewMemoryWriter()
+			err := renderfs.Copy(source, writer, renderfs.Options{Context: ctx, StrictSubscripts: strict})
+			if want && err != nil {
+				t.Errorf("%s (strict=%v): unexpected error %v", tc.tpl, strict, err)
+			}
+			if !want && (err == nil || !strings.Contains(err.Error(), "missing context value")) {
+				t.Errorf("%s (strict=%v): expected missing value error, got %v", tc.tpl, strict, err)
+			}
+			if want && tc.wantOutput != "" {
+				if got := string(writer.Contents()["out.txt"]); got != tc.wantOutput {
+					t.Errorf("%s (strict=%v): got %q", tc.tpl, strict, got)
+				}
+			}
+		}
+	}
+}
+
+func TestCopyFollowSymlinksStopsAtLoops(t *testing.T) {
+	source := fstest.MapFS{
+		"dir/file.txt": {Data: []byte("{{ name }}")},
+		"dir/self":     {Data: []byte("."), Mode: fs.ModeSymlink},
+		"dir/up":       {Data: []byte(".."), Mode: fs.ModeSymlink},
+		"a/to-b":       {Data: []byte("../b"), Mode: fs.ModeSymlink},
+		"b/to-a":       {Data: []byte("../a"), Mode: fs.ModeSymlink},
+		"b/data.txt":   {Data: []byte("b")},
+		"alias.txt":    {Data: []byte("dir/file.txt"), Mode: fs.ModeSymlink},
+	}
+	opts := renderfs.Options{
+		Context:        pongo2.Context{"name": "followed"},
+		FollowSymlinks: true,
+	}
+
+	done := make(chan error, 1)
+	writer := writers.NewMemoryWriter()
+	go func() { done <- renderfs.Copy(source, writer, opts) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Copy failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Copy did not terminate")
+	}
+
+	contents := writer.Contents()
+	if got := string(contents["alias.txt"]); got != "followed" {
+		t.Fatalf("expected file link to be rendered as a file, got %q", got)
+	}
+	if got := string(contents["a/to-b/data.txt"]); got != "b" {
+		t.Fatalf("expected directory link to be followed once, got %q", got)
+	}
+	for p := range contents {
+		if strings.Contains(p, "self/") || strings.Contains(p, "up/") || strings.Contains(p, "to-b/to-a/to-b") {
+			t.Fatalf("expected looping link to be skipped, found %s", p)
+		}
+	}
+
+	opts.FailOnSymlinkLoop = true
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "loops back") {
+		t.Fatalf("expected loop error, got %v", err)
+	}
+}
+
+func TestCopyFollowSymlinksCopiesDirectorySubtree(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"shared/module/{{ name }}.go":   "package {{ name }}\n",
+		"shared/module/sub/util.go":     "package sub\n",
+		"shared/module/sub/deep/doc.md": "# {{ name }}\n",
+	}
+	for name, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "services/api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../shared/module", filepath.Join(root, "services/api/module")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{
+		Context:        pongo2.Context{"name": "demo"},
+		FollowSymlinks: true,
+	}
+	if err := renderfs.Copy(os.DirFS(root), writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	contents := writer.Contents()
+	want := map[string]string{
+		"services/api/module/demo.go":         "package demo\n",
+		"services/api/module/sub/util.go":     "package sub\n",
+		"services/api/module/sub/deep/doc.md": "# demo\n",
+		"shared/module/demo.go":               "package demo\n",
+	}
+	for name, content := range want {
+		if got := string(contents[name]); got != content {
+			t.Fatalf("expected %s to contain %q, got %q", name, content, got)
+		}
+	}
+	if _, ok := writer.DirMode("services/api/module"); !ok {
+		t.Fatalf("expected followed link to become a directory")
+	}
+}
+
+func TestCopyOnFileRendered(t *testing.T) {
+	source := fstest.MapFS{
+		"slow.txt":  {Data: []byte(`{{ "x"|renderfs_test_sleep:20 }}`)},
+		"plain.txt": {Data: []byte("plain")},
+		"dir/{{ name }}.txt": {
+			Data: []byte("{{ name }}"),
+		},
+	}
+
+	durations := make(map[string]time.Duration)
+	sizes := make(map[string]int)
+	opts := renderfs.Options{
+		Context: pongo2.Context{"name": "demo"},
+		OnFileRendered: func(rel string, dur time.Duration, size int) {
+			durations[rel] = dur
+			sizes[rel] = size
+		},
+	}
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	want := map[string]int{"slow.txt": 1, "plain.txt": 5, "dir/{{ name }}.txt": 4}
+	if !reflect.DeepEqual(sizes, want) {
+		t.Fatalf("unexpected sizes: %v", sizes)
+	}
+	if durations["slow.txt"] <= durations["plain.txt"] {
+		t.Fatalf("expected slow template to take longer: %v", durations)
+	}
+}
+
+func TestCopyLazyContextValues(t *testing.T) {
+	source := fstest.MapFS{
+		"{{ release.tag }}.txt": {Data: []byte("{{ release.tag }} / {{ release.tag }}")},
+		"notes.txt":             {Data: []byte("{{ release.tag }}")},
+	}
+
+	calls := map[string]int{}
+	lazy := func(name string, value interface{}) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			calls[name]++
+			return value, nil
+		}
+	}
+	opts := renderfs.Options{
+		Context: pongo2.Context{
+			"release": lazy("release", map[string]interface{}{"tag": "v1.2.0"}),
+			"unused":  lazy("unused", "expensive"),
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["v1.2.0.txt"]); got != "v1.2.0 / v1.2.0" {
+		t.Fatalf("unexpected content: %q", got)
+	}
+	if calls["release"] != 1 {
+		t.Fatalf("expected referenced lazy value to be computed once, got %d", calls["release"])
+	}
+	if calls["unused"] != 0 {
+		t.Fatalf("expected unreferenced lazy value not to be computed, got %d", calls["unused"])
+	}
+
+	failing := renderfs.Options{Context: pongo2.Context{
+		"release": func() (interface{}, error) { return nil, errors.New("offline") },
+	}}
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), failing); err == nil {
+		t.Fatalf("expected a failing lazy value to abort the copy")
+	}
+}
+
+// chunkRecorder is a Writer that keeps only the size of the largest single
+// Write it receives, plus the total written per file.
+type chunkRecorder struct {
+	largestWrite int
+	totals       map[string]int
+}
+
+func (r *chunkRecorder) MkdirAll(string, fs.FileMode) error { return nil }
+func (r *chunkRecorder) Symlink(string, string) error       { return nil }
+
+func (r *chunkRecorder) CreateFile(p string, _ fs.FileMode) (io.WriteCloser, error) {
+	return &chunkRecorderFile{recorder: r, path: p}, nil
+}
+
+type chunkRecorderFile struct {
+	recorder *chunkRecorder
+	path     string
+}
+
+func (f *chunkRecorderFile) Write(p []byte) (int, error) {
+	f.recorder.largestWrite = max(f.recorder.largestWrite, len(p))
+	f.recorder.totals[f.path] += len(p)
+	return len(p), nil
+}
+
+func (f *chunkRecorderFile) Close() error { return nil }
+
+func TestCopyStreamsLargePlainFiles(t *testing.T) {
+	const size = 4 << 20
+	plain := bytes.Repeat([]byte("data {not a template} "), size/22)
+
+	source := fstest.MapFS{"large.bin": {Data: plain}}
+	recorder := &chunkRecorder{totals: map[string]int{}}
+	if err := renderfs.Copy(source, recorder, renderfs.Options{}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if recorder.totals["large.bin"] != len(plain) {
+		t.Fatalf("expected %d bytes written, got %d", len(plain), recorder.totals["large.bin"])
+	}
+	if recorder.largestWrite >= len(plain) {
+		t.Fatalf("expected the file to be streamed in chunks, got a single %d byte write", recorder.largestWrite)
+	}
+
+	// A delimiter split across read chunks still sends the file through
+	// rendering.
+	templated := append(bytes.Repeat([]byte("x"), 64<<10-1), []byte("{{ name }}")...)
+	templated = append(templated, plain...)
+	source = fstest.MapFS{"large.txt": {Data: templated}}
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: pongo2.Context{"name": "rendered"}}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if !bytes.Contains(writer.Contents()["large.txt"][:128<<10], []byte("xrendered")) {
+		t.Fatalf("expected templated large file to be rendered")
+	}
+}
+
+func BenchmarkCopyLargePlainFile(b *testing.B) {
+	source := fstest.MapFS{"large.bin": {Data: bytes.Repeat([]byte("0123456789abcdef"), 1<<20)}}
+	recorder := &chunkRecorder{totals: map[string]int{}}
+
+	b.ReportAllocs()
+	b.SetBytes(16 << 20)
+	for i := 0; i < b.N; i++ {
+		if err := renderfs.Copy(source, recorder, renderfs.Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCopyPathContext(t *testing.T) {
+	source := fstest.MapFS{
+		"shard-{{ shard }}/{{ name }}.txt": {Data: []byte("{{ name }}")},
+	}
+	opts := renderfs.Options{
+		Context:     pongo2.Context{"name": "content"},
+		PathContext: pongo2.Context{"shard": 3, "name": "users"},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["shard-3/users.txt"]); got != "content" {
+		t.Fatalf("expected path from PathContext and content from Context, got %v", writer.Contents())
+	}
+
+	opts.PathContext = pongo2.Context{"name": "users"}
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "shard") {
+		t.Fatalf("expected the path check to use PathContext, got %v", err)
+	}
+}
+
+func TestCopyReplacesDestinationSymlinks(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	if err := os.WriteFile(outside, []byte("original"), 0o644); err != nil {
+		t.Fatalf("write outside file: %v", err)
+	}
+
+	source := fstest.MapFS{"config.txt": {Data: []byte("rendered")}}
+	for _, tc := range []struct {
+		mode    renderfs.ConflictResolution
+		wantErr bool
+		want    string
+	}{
+		{mode: renderfs.Skip, want: ""},
+		{mode: renderfs.Fail, wantErr: true},
+		{mode: renderfs.Overwrite, want: "rendered"},
+	} {
+		dest := t.TempDir()
+		if err := os.Symlink(outside, filepath.Join(dest, "config.txt")); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+		writer, err := writers.NewOSWriter(dest)
+		if err != nil {
+			t.Fatalf("NewOSWriter: %v", err)
+		}
+
+		err = renderfs.Copy(source, writer, renderfs.Options{OnConflict: tc.mode})
+		if tc.wantErr != (err != nil) {
+			t.Fatalf("mode %d: unexpected error %v", tc.mode, err)
+		}
+
+		content, err := os.ReadFile(outside)
+		if err != nil || string(content) != "original" {
+			t.Fatalf("mode %d: expected link target untouched, got %q (%v)", tc.mode, content, err)
+		}
+		if tc.want == "" {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(dest, "config.txt"))
+		if err != nil || info.Mode()&fs.ModeSymlink != 0 {
+			t.Fatalf("mode %d: expected the link to be replaced by a file (%v)", tc.mode, err)
+		}
+		if got, _ := os.ReadFile(filepath.Join(dest, "config.txt")); string(got) != tc.want {
+			t.Fatalf("mode %d: got %q", tc.mode, got)
+		}
+	}
+}
+
+func TestCopyRenameGlobs(t *testing.T) {
+	source := fstest.MapFS{
+		"config.sample":         {Data: []byte("config")},
+		"app/settings.sample":   {Data: []byte("settings")},
+		"special.sample":        {Data: []byte("special")},
+		"scripts/run.sh.sample": {Data: []byte("run")},
+		"docs/guide.md":         {Data: []byte("guide")},
+	}
+	opts := renderfs.Options{
+		Rename: map[string]string{
+			"*.sample":       "{stem}",
+			"special.sample": "SPECIAL",
+			"docs/*.md":      "manual/{stem}.txt",
+		},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	want := map[string]string{
+		"config":           "config",
+		"app/settings":     "settings",
+		"SPECIAL":          "special",
+		"scripts/run.sh":   "run",
+		"manual/guide.txt": "guide",
+	}
+	contents := writer.Contents()
+	if len(contents) != len(want) {
+		t.Fatalf("unexpected output: %v", contents)
+	}
+	for p, body := range want {
+		if got := string(contents[p]); got != body {
+			t.Errorf("%s: got %q, want %q", p, got, body)
+		}
+	}
+
+	opts.Rename = map[string]string{"*.md": "../../{stem}"}
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "escapes destination") {
+		t.Fatalf("expected escaping rename to be rejected, got %v", err)
+	}
+}
+
+func TestCopyLineEndings(t *testing.T) {
+	source := fstest.MapFS{
+		"crlf.sh":   {Data: []byte("#!/bin/sh\r\necho {{ name }}\r\n")},
+		"lf.bat":    {Data: []byte("@echo off\necho {{ name }}\n")},
+		"image.bin": {Data: []byte("\x00\x01\r\n\x02\n")},
+	}
+	ctx := pongo2.Context{"name": "hi"}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: ctx, LineEndings: renderfs.LF}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["crlf.sh"]); got != "#!/bin/sh\necho hi\n" {
+		t.Fatalf("expected LF output, got %q", got)
+	}
+	if got := string(writer.Contents()["image.bin"]); got != "\x00\x01\r\n\x02\n" {
+		t.Fatalf("expected binary file untouched, got %q", got)
+	}
+
+	writer = writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: ctx, LineEndings: renderfs.CRLF}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["lf.bat"]); got != "@echo off\r\necho hi\r\n" {
+		t.Fatalf("expected CRLF output, got %q", got)
+	}
+	if got := string(writer.Contents()["crlf.sh"]); got != "#!/bin/sh\r\necho hi\r\n" {
+		t.Fatalf("expected existing CRLF not to be doubled, got %q", got)
+	}
+
+	writer = writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{Context: ctx}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["crlf.sh"]); got != "#!/bin/sh\r\necho hi\r\n" {
+		t.Fatalf("expected line endings preserved by default, got %q", got)
+	}
+}
+
+func TestCopyEnsureFinalNewline(t *testing.T) {
+	source := fstest.MapFS{
+		"missing.txt": {Data: []byte("{% if true %}value{% endif %}")},
+		"present.txt": {Data: []byte("value\n")},
+		"empty.txt":   {Data: []byte("")},
+		"crlf.txt":    {Data: []byte("a\r\nb")},
+		"blob.bin":    {Data: []byte("\x00data")},
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{EnsureFinalNewline: true}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	want := map[string]string{
+		"missing.txt": "value\n",
+		"present.txt": "value\n",
+		"empty.txt":   "",
+		"crlf.txt":    "a\r\nb\n",
+		"blob.bin":    "\x00data",
+	}
+	for p, body := range want {
+		if got := string(writer.Contents()[p]); got != body {
+			t.Errorf("%s: got %q, want %q", p, got, body)
+		}
+	}
+
+	writer = writers.NewMemoryWriter()
+	opts := renderfs.Options{EnsureFinalNewline: true, LineEndings: renderfs.CRLF}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["crlf.txt"]); got != "a\r\nb\r\n" {
+		t.Fatalf("expected CRLF terminator, got %q", got)
+	}
+}
+
+func TestCopySkipEmptyFiles(t *testing.T) {
+	source := fstest.MapFS{
+		"docker-compose.yml": {Data: []byte("{% if use_docker %}services: {}{% endif %}\n  \n")},
+		"main.go":            {Data: []byte("package main\n")},
+	}
+	opts := renderfs.Options{
+		Context:        pongo2.Context{"use_docker": false},
+		SkipEmptyFiles: true,
+	}
+
+	writer := writers.NewMemoryWriter()
+	result, err := renderfs.CopyWithResult(source, writer, opts)
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if _, ok := writer.Contents()["docker-compose.yml"]; ok {
+		t.Fatalf("expected whitespace-only file to be skipped")
+	}
+	if result.FilesWritten != 1 || result.SkippedEmpty != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	opts.SkipEmptyFiles = false
+	writer = writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if _, ok := writer.Contents()["docker-compose.yml"]; !ok {
+		t.Fatalf("expected empty file to be written by default")
+	}
+}
+
+func TestCopyDryRunInvokesHooks(t *testing.T) {
+	source := fstest.MapFS{
+		"app":                  {Mode: fs.ModeDir | 0o755},
+		"app/{{ name }}.txt":   {Data: []byte("hello {{ name }}")},
+		"app/link":             {Data: []byte("demo.txt"), Mode: fs.ModeSymlink},
+		"app/existing.txt":     {Data: []byte("new")},
+		"app/nested/empty.txt": {Data: []byte("")},
+	}
+	writer := writers.NewMemoryWriter()
+	if err := writer.MkdirAll("app", 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	handle, err := writer.CreateFile("app/existing.txt", 0o644)
+	if err != nil {
+		t.Fatalf("CreateFile failed: %v", err)
+	}
+	handle.Write([]byte("old"))
+	handle.Close()
+
+	var events []renderfs.FileEvent
+	var afterDryRun bool
+	opts := renderfs.Options{
+		Context: pongo2.Context{"name": "demo"},
+		DryRun:  true,
+		OnFile: func(event renderfs.FileEvent) error {
+			events = append(events, event)
+			return nil
+		},
+		AfterCopy: func(result renderfs.CopyResult, dryRun bool) error {
+			afterDryRun = dryRun
+			if result.FilesWritten != 3 {
+				t.Errorf("unexpected result: %+v", result)
+			}
+			return nil
+		},
+	}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	if !afterDryRun {
+		t.Fatalf("expected AfterCopy to receive dryRun=true")
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 file events, got %+v", events)
+	}
+	for _, event := range events {
+		if !event.DryRun {
+			t.Fatalf("expected dry-run event, got %+v", event)
+		}
+	}
+	if events[0].Source != "app/existing.txt" || events[1].Path != "app/nested/empty.txt" ||
+		events[2].Path != "app/demo.txt" || events[2].Size != int64(len("hello demo")) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	if got := writer.Contents(); len(got) != 1 || string(got["app/existing.txt"]) != "old" {
+		t.Fatalf("expected destination untouched, got %v", got)
+	}
+	for _, p := range []string{"app/nested", "app/link"} {
+		if _, err := writer.Lstat(p); err == nil {
+			t.Fatalf("expected %s not to be created", p)
+		}
+	}
+}
+
+func TestCopyHookErrorsAbort(t *testing.T) {
+	source := fstest.MapFS{"a.txt": {Data: []byte("a")}}
+	stop := errors.New("stop")
+
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{
+		OnFile: func(renderfs.FileEvent) error { return stop },
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected OnFile error, got %v", err)
+	}
+
+	err = renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{
+		AfterCopy: func(renderfs.CopyResult, bool) error { return stop },
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected AfterCopy error, got %v", err)
+	}
+}
+
+func TestCopyHashFunction(t *testing.T) {
+	css := []byte("body { color: {{ not_rendered }}; }\n")
+	source := fstest.MapFS{
+		"static/app.css": {Data: css},
+		"index.html":     {Data: []byte(`<link href="app.css?v={{ hash('static/app.css') }}">`)},
+	}
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{IgnorePatterns: []string{"static/"}}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	sum := sha256.Sum256(css)
+	want := `<link href="app.css?v=` + hex.EncodeToString(sum[:]) + `">`
+	if got := string(writer.Contents()["index.html"]); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	source["index.html"] = &fstest.MapFile{Data: []byte(`{{ hash('missing.css') }}`)}
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err == nil {
+		t.Fatalf("expected hashing a missing file to fail")
+	}
+
+	source["index.html"] = &fstest.MapFile{Data: []byte(`{{ hash }}`)}
+	writer = writers.NewMemoryWriter()
+	opts.Context = pongo2.Context{"hash": "shadowed"}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["index.html"]); got != "shadowed" {
+		t.Fatalf("expected context to shadow builtin, got %q", got)
+	}
+}
+
+func TestCopyExposeFileList(t *testing.T) {
+	source := fstest.MapFS{
+		"index.md":                      {Data: []byte("{% for f in __files__ %}- {{ f }}\n{% endfor %}")},
+		"docs/{{ name }}.md.jinja":      {Data: []byte("# {{ name }}")},
+		"docs/notes.txt":                {Data: []byte("notes")},
+		"{% if false %}skip{% endif %}": {Data: []byte("never")},
+		"build.log":                     {Data: []byte("noise")},
+	}
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{
+		Context:        pongo2.Context{"name": "guide"},
+		IgnorePatterns: []string{"*.log"},
+		ExposeFileList: true,
+	}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	want := "- docs/guide.md\n- docs/notes.txt\n- index.md\n"
+	if got := string(writer.Contents()["index.md"]); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if len(writer.Contents()) != 3 {
+		t.Fatalf("unexpected files: %v", writer.Contents())
+	}
+}
+
+func TestCopyDirectoryDataFiles(t *testing.T) {
+	source := fstest.MapFS{
+		".renderfs-data.yaml":        {Data: []byte("package: app\nowner:\n  team: core\n  email: core@example.com\n")},
+		"main.go":                    {Data: []byte("package {{ package }} // {{ owner.team }}")},
+		"lib/{{ package }}.go":       {Data: []byte("package {{ package }} // {{ owner.team }} {{ owner.email }}")},
+		"lib/.renderfs-data.yaml":    {Data: []byte("package: lib\nowner:\n  team: platform\n")},
+		"lib/sub/util.go":            {Data: []byte("package {{ package }}")},
+		"other/{{ package }}_doc.go": {Data: []byte("package {{ package }}")},
+	}
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{Context: pongo2.Context{"package": "ignored"}, DirectoryData: true}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	want := map[string]string{
+		"main.go":          "package app // core",
+		"lib/lib.go":       "package lib // platform core@example.com",
+		"lib/sub/util.go":  "package lib",
+		"other/app_doc.go": "package app",
+	}
+	got := writer.Contents()
+	if len(got) != len(want) {
+		t.Fatalf("unexpected files: %v", got)
+	}
+	for p, content := range want {
+		if string(got[p]) != content {
+			t.Errorf("%s = %q, want %q", p, got[p], content)
+		}
+	}
+
+	source["lib/.renderfs-data.yaml"] = &fstest.MapFile{Data: []byte("package: [unterminated\n")}
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err == nil {
+		t.Fatalf("expected invalid data file to fail")
+	}
+
+	// Without DirectoryData, data files are ordinary files.
+	opts.DirectoryData = false
+	opts.Context = pongo2.Context{"package": "plain", "owner": pongo2.Context{"team": "t", "email": "e"}}
+	writer = writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	got = writer.Contents()
+	if string(got["main.go"]) != "package plain // t" {
+		t.Fatalf("expected data files to be ignored, got %q", got["main.go"])
+	}
+	if _, ok := got["lib/.renderfs-data.yaml"]; !ok {
+		t.Fatalf("expected data file to be copied without DirectoryData")
+	}
+}
+
+func TestCopyRandSeed(t *testing.T) {
+	source := fstest.MapFS{
+		"a.txt": {Data: []byte("{{ uuid() }} {{ randint(1, 100) }}")},
+		"b.txt": {Data: []byte("{{ choice(colors) }} {{ uuid() }}")},
+	}
+	render := func(seed int64) map[string][]byte {
+		t.Helper()
+		writer := writers.NewMemoryWriter()
+		opts := renderfs.Options{
+			Context:  pongo2.Context{"colors": []string{"red", "green", "blue", "cyan"}},
+			RandSeed: seed,
+		}
+		if err := renderfs.Copy(source, writer, opts); err != nil {
+			t.Fatalf("Copy failed: %v", err)
+		}
+		return writer.Contents()
+	}
+
+	first, second := render(42), render(42)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected identical output for the same seed:\n%s\n%s", first, second)
+	}
+	if reflect.DeepEqual(first, render(43)) {
+		t.Fatalf("expected a different seed to change the output")
+	}
+
+	uuid := strings.Fields(string(first["a.txt"]))[0]
+	if len(uuid) != 36 || uuid[14] != '4' {
+		t.Fatalf("expected a version 4 UUID, got %q", uuid)
+	}
+}
+
+func TestCopyNow(t *testing.T) {
+	source := fstest.MapFS{
+		"stamp.txt": {Data: []byte(`Generated {{ now|date:"2006-01-02T15:04:05Z07:00" }}`)},
+	}
+	frozen := time.Date(2024, 3, 9, 14, 30, 0, 0, time.UTC)
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, renderfs.Options{Now: frozen}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["stamp.txt"]); got != "Generated 2024-03-09T14:30:00Z" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	writer = writers.NewMemoryWriter()
+	before := time.Now().Truncate(time.Second)
+	if err := renderfs.Copy(source, writer, renderfs.Options{}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	stamp, err := time.Parse(time.RFC3339, strings.TrimPrefix(string(writer.Contents()["stamp.txt"]), "Generated "))
+	if err != nil || stamp.Before(before) {
+		t.Fatalf("expected the current time, got %v (%v)", stamp, err)
+	}
+}
+
+func TestCopyMaxSourceFileSize(t *testing.T) {
+	source := fstest.MapFS{
+		"small.txt": {Data: []byte("{{ name }}")},
+		"huge.txt":  {Data: bytes.Repeat([]byte("{{ name }}"), 1000)},
+	}
+	opts := renderfs.Options{
+		Context:           pongo2.Context{"name": "x"},
+		MaxSourceFileSize: 100,
+	}
+
+	writer := writers.NewMemoryWriter()
+	result, err := renderfs.CopyWithResult(source, writer, opts)
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if _, ok := writer.Contents()["huge.txt"]; ok {
+		t.Fatalf("expected oversized source to be skipped")
+	}
+	if string(writer.Contents()["small.txt"]) != "x" || result.SkippedOversized != 1 || result.FilesWritten != 1 {
+		t.Fatalf("unexpected result %+v: %v", result, writer.Contents())
+	}
+
+	opts.FailOnOversizedSource = true
+	err = renderfs.Copy(source, writers.NewMemoryWriter(), opts)
+	if err == nil || !strings.Contains(err.Error(), "huge.txt") {
+		t.Fatalf("expected oversized source to fail, got %v", err)
+	}
+}
+
+type textHelpers struct{}
+
+func (textHelpers) Upper(s string) string { return strings.ToUpper(s) }
+
+func TestCopyHelperNamespace(t *testing.T) {
+	source := fstest.MapFS{
+		"greeting.txt": {Data: []byte("Hello, {{ helpers.Upper(name) }}!")},
+	}
+
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{Context: pongo2.Context{"helpers": textHelpers{}, "name": "ada"}}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["greeting.txt"]); got != "Hello, ADA!" {
+		t.Fatalf("unexpected greeting %q", got)
+	}
+
+	err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{Context: pongo2.Context{"name": "ada"}})
+	if err == nil || !strings.Contains(err.Error(), "missing context value for 'helpers'") {
+		t.Fatalf("expected missing helpers receiver, got %v", err)
+	}
+}
+
+func TestCopyKnownGlobals(t *testing.T) {
+	pongo2.Globals["site_name"] = "Acme"
+	t.Cleanup(func() { delete(pongo2.Globals, "site_name") })
+
+	source := fstest.MapFS{
+		"footer.html": {Data: []byte("&copy; {{ site_name }} {{ year }}")},
+	}
+	opts := renderfs.Options{Context: pongo2.Context{"year": 2024}}
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err == nil {
+		t.Fatalf("expected undeclared global to be reported missing")
+	}
+
+	opts.KnownGlobals = []string{"site_name"}
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["footer.html"]); got != "&copy; Acme 2024" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	vars, err := renderfs.AnalyzeSource(source, opts)
+	if err != nil {
+		t.Fatalf("AnalyzeSource failed: %v", err)
+	}
+	if got := vars["footer.html"]; !reflect.DeepEqual(got, []string{"year"}) {
+		t.Fatalf("expected known global to be left out of analysis, got %v", got)
+	}
+}
+
+var registerTranslateTag sync.Once
+
+type translateNode struct{ key string }
+
+func (n *translateNode) Execute(_ *pongo2.ExecutionContext, w pongo2.TemplateWriter) *pongo2.Error {
+	translations := map[string]string{"hello": "bonjour"}
+	w.WriteString(translations[n.key])
+	return nil
+}
+
+func TestCopyExtraKeywords(t *testing.T) {
+	registerTranslateTag.Do(func() {
+		err := pongo2.RegisterTag("translate", func(_ *pongo2.Parser, start *pongo2.Token, args *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+			key := args.MatchType(pongo2.TokenString)
+			if key == nil {
+				return nil, args.Error("translate expects a string", start)
+			}
+			return &translateNode{key: key.Val}, nil
+		})
+		if err != nil {
+			t.Fatalf("RegisterTag: %v", err)
+		}
+	})
+
+	source := fstest.MapFS{
+		"greeting.txt": {Data: []byte(`{% translate "hello" %}, {{ name }}`)},
+	}
+	opts := renderfs.Options{Context: pongo2.Context{"name": "Ana"}}
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err == nil {
+		t.Fatalf("expected custom tag keyword to be reported missing")
+	}
+
+	opts.ExtraKeywords = []string{"translate"}
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["greeting.txt"]); got != "bonjour, Ana" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestCopySnippetFS(t *testing.T) {
+	snippets := fstest.MapFS{
+		"license.txt":  {Data: []byte("// Copyright {{ year }} {{ owner }}\n")},
+		"partials.txt": {Data: []byte(`{% include "license.txt" %}`)},
+		"main.go":      {Data: []byte("shadowed by the source")},
+	}
+	source := fstest.MapFS{
+		"main.go":        {Data: []byte(`{% include "license.txt" %}package main`)},
+		"lib/lib.go":     {Data: []byte(`{% include "partials.txt" %}package lib`)},
+		"local.go.jinja": {Data: []byte(`{% include "main.go" %}`)},
+	}
+	opts := renderfs.Options{
+		Context:   pongo2.Context{"year": 2024, "owner": "Acme"},
+		SnippetFS: snippets,
+	}
+
+	writer := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	want := map[string]string{
+		"main.go":    "// Copyright 2024 Acme\npackage main",
+		"lib/lib.go": "// Copyright 2024 Acme\npackage lib",
+		"local.go":   "// Copyright 2024 Acme\npackage main",
+	}
+	if got := writer.Contents(); len(got) != len(want) {
+		t.Fatalf("unexpected files: %v", got)
+	}
+	for p, content := range want {
+		if got := string(writer.Contents()[p]); got != content {
+			t.Errorf("%s = %q, want %q", p, got, content)
+		}
+	}
+
+	opts.SnippetFS = nil
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err == nil {
+		t.Fatalf("expected includes to fail without SnippetFS")
+	}
+}
+
+func TestCopyRelpath(t *testing.T) {
+	source := fstest.MapFS{
+		"web/src/components/{{ name }}.ts": {Data: []byte(`import { api } from "{{ relpath("web/src/lib/api.ts") }}";`)},
+		"web/src/lib/api.ts":               {Data: []byte("export const api = {};")},
+		"docs/index.md":                    {Data: []byte(`[api]({{ relpath("docs/index.md", "web/src/lib/api.ts") }}) [self]({{ relpath("docs/index.md") }})`)},
+	}
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{Context: pongo2.Context{"name": "button"}}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	if got := string(writer.Contents()["web/src/components/button.ts"]); got != `import { api } from "../lib/api.ts";` {
+		t.Fatalf("unexpected import %q", got)
+	}
+	if got := string(writer.Contents()["docs/index.md"]); got != "[api](../web/src/lib/api.ts) [self](index.md)" {
+		t.Fatalf("unexpected links %q", got)
+	}
+}
+
+func TestCopyTransactional(t *testing.T) {
+	source := fstest.MapFS{
+		"a.txt":     {Data: []byte("new a")},
+		"b/b.txt":   {Data: []byte("new b")},
+		"c/c.txt":   {Data: []byte("new c")},
+		"link":      {Data: []byte("a.txt"), Mode: fs.ModeSymlink},
+		"z/last.md": {Data: []byte("last")},
+	}
+	failLate := func(event renderfs.FileEvent) error {
+		if event.Path == "z/last.md" {
+			return errors.New("late failure")
+		}
+		return nil
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		writer := writers.NewMemoryWriter()
+		handle, _ := writer.CreateFile("a.txt", 0o644)
+		handle.Write([]byte("old a"))
+		handle.Close()
+
+		opts := renderfs.Options{Transactional: true, OnFile: failLate}
+		if err := renderfs.Copy(source, writer, opts); err == nil {
+			t.Fatalf("expected late failure")
+		}
+		if got := writer.Contents(); len(got) != 1 || string(got["a.txt"]) != "old a" {
+			t.Fatalf("expected destination untouched, got %v", got)
+		}
+
+		opts.OnFile = nil
+		if err := renderfs.Copy(source, writer, opts); err != nil {
+			t.Fatalf("Copy failed: %v", err)
+		}
+		if got := writer.Contents(); len(got) != 4 || string(got["a.txt"]) != "new a" {
+			t.Fatalf("expected committed output, got %v", got)
+		}
+	})
+
+	t.Run("os", func(t *testing.T) {
+		parent := t.TempDir()
+		dest := filepath.Join(parent, "out")
+		if err := os.MkdirAll(dest, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dest, "a.txt"), []byte("old a"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dest, "keep.txt"), []byte("keep"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		writer, err := writers.NewOSWriter(dest)
+		if err != nil {
+			t.Fatalf("NewOSWriter: %v", err)
+		}
+
+		opts := renderfs.Options{Transactional: true, OnFile: failLate}
+		if err := renderfs.Copy(source, writer, opts); err == nil {
+			t.Fatalf("expected late failure")
+		}
+		entries, _ := os.ReadDir(dest)
+		if len(entries) != 2 {
+			t.Fatalf("expected destination untouched, found %v", entries)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dest, "a.txt")); string(data) != "old a" {
+			t.Fatalf("expected a.txt untouched, got %q", data)
+		}
+
+		opts.OnFile = nil
+		if err := renderfs.Copy(source, writer, opts); err != nil {
+			t.Fatalf("Copy failed: %v", err)
+		}
+		for p, want := range map[string]string{"a.txt": "new a", "b/b.txt": "new b", "keep.txt": "keep", "z/last.md": "last"} {
+			if data, err := os.ReadFile(filepath.Join(dest, p)); err != nil || string(data) != want {
+				t.Fatalf("%s = %q, %v; want %q", p, data, err, want)
+			}
+		}
+		if target, err := os.Readlink(filepath.Join(dest, "link")); err != nil || target != "a.txt" {
+			t.Fatalf("expected link to be committed, got %q, %v", target, err)
+		}
+
+		fresh := filepath.Join(parent, "fresh")
+		writer, _ = writers.NewOSWriter(fresh)
+		if err := renderfs.Copy(source, writer, renderfs.Options{Transactional: true}); err != nil {
+			t.Fatalf("Copy into new directory failed: %v", err)
+		}
+		if data, err := os.ReadFile(filepath.Join(fresh, "c/c.txt")); err != nil || string(data) != "new c" {
+			t.Fatalf("c/c.txt = %q, %v", data, err)
+		}
+
+		leftovers, _ := os.ReadDir(parent)
+		if len(leftovers) != 2 {
+			t.Fatalf("expected staging directories to be cleaned up, found %v", leftovers)
+		}
+	})
+
+	if err := renderfs.Copy(source, writers.Prefixed(writers.NewMemoryWriter(), "x"), renderfs.Options{Transactional: true}); err == nil {
+		t.Fatalf("expected writers without staging support to be rejected")
+	}
+}
+
+func TestCopyEnvVars(t *testing.T) {
+	t.Setenv("RENDERFS_TEST_USER", "ana")
+	t.Setenv("RENDERFS_TEST_SECRET", "hunter2")
+
+	source := fstest.MapFS{
+		"whoami.txt": {Data: []byte("{{ env.RENDERFS_TEST_USER }}")},
+	}
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{EnvVars: []string{"RENDERFS_TEST_USER", "RENDERFS_TEST_UNSET"}}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["whoami.txt"]); got != "ana" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	for _, tpl := range []string{"{{ env.RENDERFS_TEST_SECRET }}", "{{ env.RENDERFS_TEST_UNSET }}"} {
+		source := fstest.MapFS{"out.txt": {Data: []byte(tpl)}}
+		if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err == nil {
+			t.Errorf("expected %s to be unavailable", tpl)
+		}
+	}
+	if err := renderfs.Copy(source, writers.NewMemoryWriter(), renderfs.Options{}); err == nil {
+		t.Fatalf("expected env to be absent without EnvVars")
+	}
+}
+
+func TestCopyOnceGlobs(t *testing.T) {
+	source := fstest.MapFS{
+		"config/settings.yaml": {Data: []byte("version: {{ version }}\n")},
+		"VERSION":              {Data: []byte("{{ version }}")},
+	}
+	writer := writers.NewMemoryWriter()
+	opts := renderfs.Options{
+		Context:   pongo2.Context{"version": 1},
+		OnceGlobs: []string{"settings.yaml"},
+	}
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["config/settings.yaml"]); got != "version: 1\n" {
+		t.Fatalf("expected once-file on first run, got %q", got)
+	}
+
+	opts.Context = pongo2.Context{"version": 2}
+	opts.OnConflict = renderfs.Overwrite
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(writer.Contents()["config/settings.yaml"]); got != "version: 1\n" {
+		t.Fatalf("expected once-file to be preserved, got %q", got)
+	}
+	if got := string(writer.Contents()["VERSION"]); got != "2" {
+		t.Fatalf("expected other files to be overwritten, got %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+package renderfs
+
+import "fmt"
+
+// SkipReason explains why Copy left a source entry out of the destination.
+type SkipReason int
+
+const (
+	// SkipIgnored marks an entry matched by ignore patterns, including
+	// IgnoreRenderedPatterns.
+	SkipIgnored SkipReason = iota
+	// SkipEmptyPath marks an entry whose path rendered empty.
+	SkipEmptyPath
+	// SkipConflict marks a file that already exists while OnConflict is Skip.
+	SkipConflict
+	// SkipFiltered marks an entry dropped by Rename or PathMapper, or a keep
+	// marker dropped by DropKeepMarker or Flatten.
+	SkipFiltered
+	// SkipEmptyContent marks a file that rendered blank while SkipEmptyFiles
+	// is set.
+	SkipEmptyContent
+	// SkipOnce marks an existing file matched by OnceGlobs.
+	SkipOnce
+	// SkipMaxDepth marks an entry deeper than MaxDepth.
+	SkipMaxDepth
+	// SkipUnchanged marks a file left alone by SkipUnchanged or
+	// PriorManifest.
+	SkipUnchanged
+	// SkipOversized marks a source file larger than MaxSourceFileSize.
+	SkipOversized
+	// SkipSymlinkLoop marks a followed directory link that leads back to one
+	// of its own ancestors.
+	SkipSymlinkLoop
+	// SkipCondition marks an entry inside a directory whose .renderfs-if
+	// condition rendered blank.
+	SkipCondition
+)
+
+var skipReasonNames = [...]string{
+	SkipIgnored:      "ignored",
+	SkipEmptyPath:    "empty path",
+	SkipConflict:     "conflict",
+	SkipFiltered:     "filtered",
+	SkipEmptyContent: "empty content",
+	SkipOnce:         "once",
+	SkipMaxDepth:     "max depth",
+	SkipUnchanged:    "unchanged",
+	SkipOversized:    "oversized",
+	SkipSymlinkLoop:  "symlink loop",
+	SkipCondition:    "condition",
+}
+
+// String returns a short lower-case description such as "ignored".
+func (r SkipReason) String() string {
+	if r >= 0 && int(r) < len(skipReasonNames) {
+		return skipReasonNames[r]
+	}
+	return fmt.Sprintf("SkipReason(%d)", int(r))
+}
+
+// skipped reports rel to Options.OnSkip. Only the pass that writes files
+// reports, so each entry is reported once.
+func (c *copier) skipped(rel string, reason SkipReason) {
+	if c.opts.OnSkip != nil && !c.listOnly && !c.dirsOnly {
+		c.opts.OnSkip(rel, reason)
+	}
+}
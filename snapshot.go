@@ -0,0 +1,66 @@
+package renderfs
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/your-org/renderfs/internal/memfs"
+)
+
+// Snapshot reads source into memory and returns it as a read-only fs.FS, so
+// repeated copies from a slow source, such as a network filesystem, read from
+// RAM instead. Entries Copy would ignore under opts are left out, so copying
+// from the snapshot with the same options produces the same output as copying
+// from source, provided templates do not include or hash ignored files.
+// Symlinks are kept as links. The snapshot is safe for concurrent use.
+func Snapshot(source fs.FS, opts Options) (fs.FS, error) {
+	if source == nil {
+		return nil, fmt.Errorf("renderfs: source filesystem is required")
+	}
+	matcher, err := buildIgnoreMatcher(source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := memfs.New()
+	err = fs.WalkDir(source, ".", func(rel string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if rel == "." {
+			return nil
+		}
+		if isIgnored(matcher, rel, d.IsDir(), opts.IncludeIgnoreFile) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("renderfs: stat %s: %w", rel, err)
+		}
+		switch {
+		case d.IsDir():
+			snapshot.AddDir(rel, info.Mode())
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := readSymlink(source, rel)
+			if err != nil {
+				return fmt.Errorf("renderfs: read symlink %s: %w", rel, err)
+			}
+			snapshot.AddSymlink(rel, target)
+		default:
+			content, err := fs.ReadFile(source, rel)
+			if err != nil {
+				return fmt.Errorf("renderfs: read %s: %w", rel, err)
+			}
+			snapshot.AddFile(rel, info.Mode(), content)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,91 @@
+package renderfs_test
+
+import (
+	"fmt"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/your-org/renderfs"
+	"github.com/your-org/renderfs/writers"
+)
+
+func TestSnapshot(t *testing.T) {
+	source := fstest.MapFS{
+		".renderfs-ignore":          {Data: []byte("node_modules/\n")},
+		"README.md.tmpl":            {Data: []byte("# {{ name }}\n")},
+		"bin/run.sh":                {Data: []byte("#!/bin/sh\n"), Mode: 0o755},
+		"docs/link.md":              {Data: []byte("README.md"), Mode: fs.ModeSymlink | 0o777},
+		"node_modules/pkg/index.js": {Data: []byte("huge")},
+	}
+	opts := renderfs.Options{Context: pongo2.Context{"name": "demo"}}
+
+	snapshot, err := renderfs.Snapshot(source, opts)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if _, err := fs.Stat(snapshot, "node_modules/pkg/index.js"); err == nil {
+		t.Fatalf("expected ignored files to be left out of the snapshot")
+	}
+	if target, err := fs.ReadLink(snapshot, "docs/link.md"); err != nil || target != "README.md" {
+		t.Fatalf("expected symlink to be kept, got %q (%v)", target, err)
+	}
+
+	fromSource := writers.NewMemoryWriter()
+	if err := renderfs.Copy(source, fromSource, opts); err != nil {
+		t.Fatalf("Copy from source failed: %v", err)
+	}
+	fromSnapshot := writers.NewMemoryWriter()
+	if err := renderfs.Copy(snapshot, fromSnapshot, opts); err != nil {
+		t.Fatalf("Copy from snapshot failed: %v", err)
+	}
+	if !reflect.DeepEqual(fromSnapshot.Contents(), fromSource.Contents()) {
+		t.Fatalf("snapshot output differs:\n got %v\nwant %v", fromSnapshot.Contents(), fromSource.Contents())
+	}
+	if mode, ok := fromSnapshot.FileMode("bin/run.sh"); !ok || mode != 0o755 {
+		t.Fatalf("expected mode to survive the snapshot, got %v (ok=%v)", mode, ok)
+	}
+}
+
+// slowFS delays every Open, like a network-backed source.
+type slowFS struct {
+	fs.FS
+	delay time.Duration
+}
+
+func (s slowFS) Open(name string) (fs.File, error) {
+	time.Sleep(s.delay)
+	return s.FS.Open(name)
+}
+
+func BenchmarkCopySnapshot(b *testing.B) {
+	tree := fstest.MapFS{}
+	for i := range 50 {
+		tree[fmt.Sprintf("pkg%d/file.go.tmpl", i)] = &fstest.MapFile{Data: []byte("package {{ name }}\n")}
+	}
+	source := slowFS{FS: tree, delay: 100 * time.Microsecond}
+	opts := renderfs.Options{Context: pongo2.Context{"name": "demo"}}
+
+	b.Run("slow", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := renderfs.Copy(source, writers.NewMemoryWriter(), opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("snapshot", func(b *testing.B) {
+		snapshot, err := renderfs.Snapshot(source, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := renderfs.Copy(snapshot, writers.NewMemoryWriter(), opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
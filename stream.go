@@ -0,0 +1,116 @@
+package renderfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// streamThreshold is the size from which plain files are streamed rather
+// than read into memory. Smaller files are cheaper to read once.
+const streamThreshold = 1 << 20
+
+// streamPlainFile copies a large file that contains no template syntax
+// straight from the source to the destination, without holding it in memory.
+// It reports false when the file must go through the rendering path instead:
+// it is small, contains template syntax, or an option needs the whole
+// content.
+func (c *copier) streamPlainFile(rel, renderedRel string, info fs.FileInfo) (bool, error) {
+	if info.Size() < streamThreshold || c.opts.FrontMatter || c.opts.SkipUnchanged ||
+		c.opts.LineEndings != PreserveLineEndings || c.opts.EnsureFinalNewline ||
+		c.opts.Transformers[path.Ext(renderedRel)] != nil ||
+		(c.opts.ValidateStructured && structuredFormat(renderedRel) != "") {
+		return false, nil
+	}
+
+	var h hash.Hash
+	if c.manifest != nil || c.prior != nil {
+		h = sha256.New()
+	}
+
+	started := time.Now()
+	plain, blank, size, err := scanPlain(c.source, rel, h)
+	if err != nil || !plain {
+		return false, err
+	}
+	if c.opts.OnFileRendered != nil {
+		c.opts.OnFileRendered(rel, time.Since(started), int(size))
+	}
+	if c.opts.SkipEmptyFiles && blank {
+		c.result.SkippedEmpty++
+		c.skipped(rel, SkipEmptyContent)
+		return true, nil
+	}
+	if limit := c.opts.MaxRenderedSize; limit > 0 && size > limit {
+		return false, fmt.Errorf("renderfs: rendered %s is %d bytes, exceeding limit of %d", rel, size, limit)
+	}
+
+	var sum string
+	if h != nil {
+		sum = hex.EncodeToString(h.Sum(nil))
+	}
+	if c.manifest != nil {
+		c.manifest[renderedRel] = sum
+	}
+	if c.unchangedSincePrior(renderedRel, sum) {
+		c.result.SkippedUnchanged++
+		c.skipped(rel, SkipUnchanged)
+		return true, nil
+	}
+
+	f, err := c.source.Open(rel)
+	if err != nil {
+		return false, fmt.Errorf("renderfs: read %s: %w", rel, err)
+	}
+	defer f.Close()
+	return true, c.writeFile(rel, renderedRel, fileMode(info), f)
+}
+
+var templateDelimiters = [][]byte{[]byte("{{"), []byte("{%"), []byte("{#")}
+
+// scanPlain reads rel in chunks and reports whether it is free of template
+// syntax and whether it holds only whitespace, along with its size. Content
+// is fed to h when it is non-nil.
+func scanPlain(source fs.FS, rel string, h hash.Hash) (plain, blank bool, size int64, err error) {
+	f, err := source.Open(rel)
+	if err != nil {
+		return false, false, 0, fmt.Errorf("renderfs: read %s: %w", rel, err)
+	}
+	defer f.Close()
+
+	var last byte
+	blank = true
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			if last == '{' && (chunk[0] == '{' || chunk[0] == '%' || chunk[0] == '#') {
+				return false, false, 0, nil
+			}
+			for _, delim := range templateDelimiters {
+				if bytes.Contains(chunk, delim) {
+					return false, false, 0, nil
+				}
+			}
+			if h != nil {
+				h.Write(chunk)
+			}
+			blank = blank && len(bytes.TrimSpace(chunk)) == 0
+			size += int64(n)
+			last = chunk[n-1]
+		}
+		if err == io.EOF {
+			return true, blank, size, nil
+		}
+		if err != nil {
+			return false, false, 0, fmt.Errorf("renderfs: read %s: %w", rel, err)
+		}
+	}
+}
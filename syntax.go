@@ -0,0 +1,90 @@
+package renderfs
+
+// TokenType classifies a Token produced by Tokenize.
+type TokenType int
+
+const (
+	// TokenIdentifier is a name such as a variable, attribute, or keyword.
+	TokenIdentifier TokenType = TokenType(tokenIdentifier)
+	// TokenNumber is an integer or decimal literal.
+	TokenNumber TokenType = TokenType(tokenNumber)
+	// TokenString is a quoted string literal, quotes included.
+	TokenString TokenType = TokenType(tokenString)
+	// TokenSymbol is any other single byte, such as '.', '[', or '|'.
+	TokenSymbol TokenType = TokenType(tokenSymbol)
+)
+
+// Token is a lexical unit of a template expression.
+type Token struct {
+	Type  TokenType
+	Value string
+}
+
+// Tokenize splits a template expression (the inside of a {{ }} or {% %}
+// block) into the tokens renderfs uses to find variable references.
+// Whitespace between tokens is dropped.
+func Tokenize(expr string) []Token {
+	internal := tokenize(expr)
+	tokens := make([]Token, len(internal))
+	for i, tok := range internal {
+		tokens[i] = Token{Type: TokenType(tok.typ), Value: tok.value}
+	}
+	return tokens
+}
+
+// SubscriptKind classifies a Subscript.
+type SubscriptKind int
+
+const (
+	// SubscriptDynamic is an index that cannot be evaluated statically, such
+	// as a variable or an expression.
+	SubscriptDynamic SubscriptKind = SubscriptKind(indexKindUnknown)
+	// SubscriptInt is an integer literal index.
+	SubscriptInt SubscriptKind = SubscriptKind(indexKindInt)
+	// SubscriptString is a quoted string key.
+	SubscriptString SubscriptKind = SubscriptKind(indexKindString)
+)
+
+// Subscript is one bracketed index following a path segment.
+type Subscript struct {
+	Kind SubscriptKind
+
+	// Int holds the index when Kind is SubscriptInt.
+	Int int
+
+	// String holds the unquoted key when Kind is SubscriptString.
+	String string
+
+	// Expr holds the bracket contents when Kind is SubscriptDynamic.
+	Expr string
+}
+
+// Segment is one dot-separated part of a variable path, with any subscripts
+// that follow it.
+type Segment struct {
+	Name       string
+	Subscripts []Subscript
+}
+
+// ParseVariablePath parses a variable path such as users[0].name into the
+// segments checked against the context before rendering.
+func ParseVariablePath(path string) ([]Segment, error) {
+	internal, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]Segment, len(internal))
+	for i, seg := range internal {
+		segments[i].Name = seg.name
+		for _, sub := range seg.subscripts {
+			segments[i].Subscripts = append(segments[i].Subscripts, Subscript{
+				Kind:   SubscriptKind(sub.kind),
+				Int:    sub.intValue,
+				String: sub.stringValue,
+				Expr:   sub.expr,
+			})
+		}
+	}
+	return segments, nil
+}
@@ -0,0 +1,82 @@
+package renderfs_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/your-org/renderfs"
+)
+
+func TestParseVariablePath(t *testing.T) {
+	got, err := renderfs.ParseVariablePath(`users[0].tags["x"].items[i]`)
+	if err != nil {
+		t.Fatalf("ParseVariablePath failed: %v", err)
+	}
+	want := []renderfs.Segment{
+		{Name: "users", Subscripts: []renderfs.Subscript{{Kind: renderfs.SubscriptInt, Int: 0}}},
+		{Name: "tags", Subscripts: []renderfs.Subscript{{Kind: renderfs.SubscriptString, String: "x"}}},
+		{Name: "items", Subscripts: []renderfs.Subscript{{Kind: renderfs.SubscriptDynamic, Expr: "i"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected segments:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	if _, err := renderfs.ParseVariablePath("users[0"); err == nil {
+		t.Fatalf("expected unbalanced bracket to fail")
+	}
+}
+
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range []string{
+		`user.name|upper`,
+		`items[0]["key"] + 1.5`,
+		`'it\'s' ~ "quoted"`,
+		`for x in xs`,
+		`"unterminated`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, expr string) {
+		// Tokens appear in order and only whitespace separates them.
+		rest := expr
+		for _, tok := range renderfs.Tokenize(expr) {
+			if tok.Value == "" {
+				t.Fatalf("empty token in %q", expr)
+			}
+			trimmed := strings.TrimLeft(rest, " \t\n\r")
+			if !strings.HasPrefix(trimmed, tok.Value) {
+				t.Fatalf("token %q does not follow %q in %q", tok.Value, rest, expr)
+			}
+			rest = trimmed[len(tok.Value):]
+		}
+		if strings.TrimLeft(rest, " \t\n\r") != "" {
+			t.Fatalf("unconsumed input %q in %q", rest, expr)
+		}
+	})
+}
+
+func FuzzParseVariablePath(f *testing.F) {
+	for _, seed := range []string{
+		`a.b.c`,
+		`users[0].name`,
+		`m["k"]['j'][x]`,
+		`a[[b]]`,
+		`a[`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		segments, err := renderfs.ParseVariablePath(path)
+		if err != nil {
+			return
+		}
+		for _, seg := range segments {
+			if strings.ContainsAny(seg.Name, ".[") {
+				t.Fatalf("segment name %q of %q contains a separator", seg.Name, path)
+			}
+		}
+	})
+}
@@ -2,15 +2,19 @@ package renderfs
 
 import (
 	"fmt"
+	"io/fs"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/flosch/pongo2/v6"
 )
 
 var (
-	templateCache sync.Map // map[string]*pongo2.Template
+	templateCache  sync.Map // map[string]*pongo2.Template
+	candidateCache sync.Map // map[string][]variableCandidate
 
 	expressionBlockRegex = regexp.MustCompile(`{{-?([^{}]+?)-?}}`)
 	tagBlockRegex        = regexp.MustCompile(`{%-?([^{}]+?)-?%}`)
@@ -18,53 +22,169 @@ var (
 
 var (
 	skipBaseIdentifiers = map[string]struct{}{
-		"true":    {},
-		"false":   {},
-		"none":    {},
-		"null":    {},
-		"not":     {},
-		"and":     {},
-		"or":      {},
-		"in":      {},
-		"as":      {},
-		"for":     {},
-		"end":     {},
-		"if":      {},
-		"elif":    {},
-		"else":    {},
-		"set":     {},
-		"block":   {},
-		"scoped":  {},
-		"with":    {},
-		"import":  {},
-		"from":    {},
-		"macro":   {},
-		"call":    {},
-		"loop":    {},
-		"forloop": {},
-		"super":   {},
-		"self":    {},
-		"pongo2":  {}, // provided automatically
+		"true":      {},
+		"false":     {},
+		"none":      {},
+		"null":      {},
+		"not":       {},
+		"and":       {},
+		"or":        {},
+		"in":        {},
+		"as":        {},
+		"for":       {},
+		"end":       {},
+		"if":        {},
+		"elif":      {},
+		"else":      {},
+		"set":       {},
+		"block":     {},
+		"scoped":    {},
+		"with":      {},
+		"import":    {},
+		"include":   {},
+		"extends":   {},
+		"only":      {},
+		"if_exists": {},
+		"from":      {},
+		"macro":     {},
+		"call":      {},
+		"loop":      {},
+		"forloop":   {},
+		"super":     {},
+		"self":      {},
+		"pongo2":    {}, // provided automatically
 	}
 )
 
-func renderTemplateString(tpl string, ctx pongo2.Context) (string, error) {
-	if err := ensureVariablesPresent(tpl, ctx); err != nil {
+// renderer carries the per-Copy settings that influence template rendering.
+// It is safe for concurrent use.
+type renderer struct {
+	timeout          time.Duration
+	strictSubscripts bool
+
+	// skip holds the base identifiers the missing-variable check ignores.
+	skip map[string]struct{}
+
+	// source backs include, extends, and import tags, falling back to
+	// Options.SnippetFS when set. Templates using them are compiled against
+	// set and cached in local, since their meaning depends on the source
+	// filesystem.
+	source fs.FS
+	set    *pongo2.TemplateSet
+	local  sync.Map // map[string]*pongo2.Template
+}
+
+func newRenderer(source fs.FS, opts Options) *renderer {
+	if opts.SnippetFS != nil {
+		source = fallbackFS{primary: source, fallback: opts.SnippetFS}
+	}
+	return &renderer{
+		timeout:          opts.RenderTimeout,
+		strictSubscripts: opts.StrictSubscripts,
+		skip:             skipIdentifiers(opts),
+		source:           source,
+		set:              newTemplateSet(source),
+	}
+}
+
+// hasTemplateSyntax reports whether s contains any pongo2 delimiter. Strings
+// without one render to themselves.
+func hasTemplateSyntax(s string) bool {
+	return strings.Contains(s, "{{") || strings.Contains(s, "{%") || strings.Contains(s, "{#")
+}
+
+func (r *renderer) renderTemplateString(tpl string, ctx pongo2.Context) (string, error) {
+	if !hasTemplateSyntax(tpl) {
+		return tpl, nil
+	}
+
+	if err := ensureVariablesPresent(tpl, ctx, r.strictSubscripts, r.skip); err != nil {
+		return "", err
+	}
+	if err := r.ensureIncludedVariablesPresent(tpl, ctx); err != nil {
 		return "", err
 	}
 
-	compiled, err := getOrCompileTemplate(tpl)
+	compiled, err := r.compile(tpl)
 	if err != nil {
 		return "", err
 	}
 
-	out, err := compiled.Execute(ctx)
+	out, err := r.execute(compiled, ctx)
 	if err != nil {
 		return "", err
 	}
 	return out, nil
 }
 
+// ensureIncludedVariablesPresent applies the missing-variable check to the
+// templates tpl loads, which render with the same context. Blocks a child
+// overrides are still checked in the base template.
+func (r *renderer) ensureIncludedVariablesPresent(tpl string, ctx pongo2.Context) error {
+	if !referencesTemplates(tpl) {
+		return nil
+	}
+	for _, included := range includedTemplates(r.source, tpl) {
+		if err := ensureVariablesPresent(included.content, ctx, r.strictSubscripts, r.skip); err != nil {
+			return fmt.Errorf("%w in %s", err, included.name)
+		}
+	}
+	return nil
+}
+
+// execute runs the compiled template, abandoning it once the configured
+// timeout elapses. pongo2 cannot be interrupted, so a timed-out execution keeps
+// running in its goroutine until it finishes on its own.
+func (r *renderer) execute(compiled *pongo2.Template, ctx pongo2.Context) (string, error) {
+	if r.timeout <= 0 {
+		return compiled.Execute(ctx)
+	}
+
+	type outcome struct {
+		out string
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		out, err := compiled.Execute(ctx)
+		done <- outcome{out: out, err: err}
+	}()
+
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		return res.out, res.err
+	case <-timer.C:
+		return "", fmt.Errorf("renderfs: rendering exceeded timeout of %s", r.timeout)
+	}
+}
+
+// compile returns the compiled form of tpl. Self-contained templates share
+// the package-wide cache; templates that load other templates are compiled
+// against the source filesystem after checking for include cycles.
+func (r *renderer) compile(tpl string) (*pongo2.Template, error) {
+	if !referencesTemplates(tpl) {
+		return getOrCompileTemplate(tpl)
+	}
+
+	if cached, ok := r.local.Load(tpl); ok {
+		return cached.(*pongo2.Template), nil
+	}
+	if err := checkIncludeCycles(r.source, tpl); err != nil {
+		return nil, err
+	}
+
+	compiled, err := r.set.FromString(tpl)
+	if err != nil {
+		return nil, newCompileError(r.source, tpl, err)
+	}
+
+	r.local.Store(tpl, compiled)
+	return compiled, nil
+}
+
 func getOrCompileTemplate(tpl string) (*pongo2.Template, error) {
 	if cached, ok := templateCache.Load(tpl); ok {
 		return cached.(*pongo2.Template), nil
@@ -72,49 +192,146 @@ func getOrCompileTemplate(tpl string) (*pongo2.Template, error) {
 
 	compiled, err := pongo2.FromString(tpl)
 	if err != nil {
-		return nil, err
+		return nil, newCompileError(nil, tpl, err)
 	}
 
 	templateCache.Store(tpl, compiled)
 	return compiled, nil
 }
 
-func ensureVariablesPresent(tpl string, ctx pongo2.Context) error {
+// cachedCandidates returns the variable candidates for tpl, extracting them
+// once per distinct template string.
+func cachedCandidates(tpl string) []variableCandidate {
+	if cached, ok := candidateCache.Load(tpl); ok {
+		return cached.([]variableCandidate)
+	}
+
 	candidates := collectVariableCandidates(tpl)
+	candidateCache.Store(tpl, candidates)
+	return candidates
+}
+
+func ensureVariablesPresent(tpl string, ctx pongo2.Context, strictSubscripts bool, skip map[string]struct{}) error {
+	candidates := cachedCandidates(tpl)
 	if len(candidates) == 0 {
 		return nil
 	}
 
 	for _, candidate := range candidates {
-		if _, skip := skipBaseIdentifiers[candidate.base]; skip {
+		if _, skipped := skip[candidate.base]; skipped {
 			continue
 		}
-		if ok := resolvePath(ctx, candidate.path); !ok {
+		if ok := resolvePath(ctx, candidate.path, strictSubscripts); !ok {
 			return fmt.Errorf("renderfs: missing context value for '%s'", candidate.path)
 		}
 	}
 	return nil
 }
 
+// ExtractVariables returns the sorted, de-duplicated context paths referenced
+// by tpl, excluding template keywords and loop helpers. These are the paths
+// Copy requires to be present in the context.
+func ExtractVariables(tpl string) []string {
+	return extractVariables(tpl, skipBaseIdentifiers)
+}
+
+func extractVariables(tpl string, skip map[string]struct{}) []string {
+	var paths []string
+	for _, candidate := range collectVariableCandidates(tpl) {
+		if _, skipped := skip[candidate.base]; skipped {
+			continue
+		}
+		paths = append(paths, candidate.path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// skipIdentifiers returns skipBaseIdentifiers extended with
+// Options.KnownGlobals and Options.ExtraKeywords.
+func skipIdentifiers(opts Options) map[string]struct{} {
+	if len(opts.KnownGlobals) == 0 && len(opts.ExtraKeywords) == 0 {
+		return skipBaseIdentifiers
+	}
+	skip := make(map[string]struct{}, len(skipBaseIdentifiers)+len(opts.KnownGlobals)+len(opts.ExtraKeywords))
+	for name := range skipBaseIdentifiers {
+		skip[name] = struct{}{}
+	}
+	for _, name := range opts.KnownGlobals {
+		skip[name] = struct{}{}
+	}
+	for _, name := range opts.ExtraKeywords {
+		skip[name] = struct{}{}
+	}
+	return skip
+}
+
 type variableCandidate struct {
 	path string
 	base string
 }
 
 func collectVariableCandidates(tpl string) []variableCandidate {
-	var result []variableCandidate
-	for _, match := range expressionBlockRegex.FindAllStringSubmatch(tpl, -1) {
-		expr := strings.TrimSpace(match[1])
-		result = append(result, extractVariablesFromExpression(expr)...)
+	if !hasTemplateSyntax(tpl) {
+		return nil
+	}
+
+	type block struct {
+		start int
+		expr  string
+		tag   bool
+	}
+	var blocks []block
+	for _, loc := range expressionBlockRegex.FindAllStringSubmatchIndex(tpl, -1) {
+		blocks = append(blocks, block{start: loc[0], expr: strings.TrimSpace(tpl[loc[2]:loc[3]])})
 	}
-	for _, match := range tagBlockRegex.FindAllStringSubmatch(tpl, -1) {
-		expr := strings.TrimSpace(match[1])
-		result = append(result, extractVariablesFromExpression(expr)...)
+	for _, loc := range tagBlockRegex.FindAllStringSubmatchIndex(tpl, -1) {
+		blocks = append(blocks, block{start: loc[0], expr: strings.TrimSpace(tpl[loc[2]:loc[3]]), tag: true})
 	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].start < blocks[j].start })
+
+	// scopes holds the names bound by each enclosing for tag. Their uses
+	// inside the loop body are provided by the loop rather than the context.
+	var scopes []map[string]struct{}
+	bound := func(name string) bool {
+		for _, scope := range scopes {
+			if _, ok := scope[name]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
 	dedup := make(map[string]variableCandidate)
-	for _, candidate := range result {
-		if _, exists := dedup[candidate.path]; !exists {
-			dedup[candidate.path] = candidate
+	for _, b := range blocks {
+		expr := b.expr
+		var loop map[string]struct{}
+		if b.tag {
+			switch tagName(expr) {
+			case "for":
+				// The names before "in" are bound, not read; the iterable
+				// is evaluated outside the new scope.
+				loop = loopVariables(expr)
+				if i := strings.Index(expr, " in "); i >= 0 {
+					expr = expr[i+len(" in "):]
+				}
+			case "endfor":
+				if len(scopes) > 0 {
+					scopes = scopes[:len(scopes)-1]
+				}
+				continue
+			}
+		}
+		for _, candidate := range extractVariablesFromExpression(expr) {
+			if bound(candidate.base) {
+				continue
+			}
+			if _, exists := dedup[candidate.path]; !exists {
+				dedup[candidate.path] = candidate
+			}
+		}
+		if loop != nil {
+			scopes = append(scopes, loop)
 		}
 	}
 
@@ -125,6 +342,30 @@ func collectVariableCandidates(tpl string) []variableCandidate {
 	return out
 }
 
+// tagName returns the first identifier of a tag expression, such as "for".
+func tagName(expr string) string {
+	tokens := tokenize(expr)
+	if len(tokens) == 0 || tokens[0].typ != tokenIdentifier {
+		return ""
+	}
+	return tokens[0].value
+}
+
+// loopVariables returns the names a for tag binds, such as item in
+// "for item in items" or k and v in "for k, v in m".
+func loopVariables(expr string) map[string]struct{} {
+	bound := make(map[string]struct{})
+	for _, tok := range tokenize(expr)[1:] {
+		if tok.typ == tokenIdentifier && tok.value == "in" {
+			break
+		}
+		if tok.typ == tokenIdentifier {
+			bound[tok.value] = struct{}{}
+		}
+	}
+	return bound
+}
+
 type tokenType int
 
 const (
@@ -154,6 +395,8 @@ func extractVariablesFromExpression(expr string) []variableCandidate {
 
 		pathBuilder := strings.Builder{}
 		pathBuilder.WriteString(tok.value)
+		// receiverLen is the length of the path before its last segment.
+		receiverLen := 0
 		j := i + 1
 		for j < len(tokens) {
 			switch tokens[j].typ {
@@ -161,6 +404,7 @@ func extractVariablesFromExpression(expr string) []variableCandidate {
 				switch tokens[j].value {
 				case ".":
 					if j+1 < len(tokens) && tokens[j+1].typ == tokenIdentifier {
+						receiverLen = pathBuilder.Len()
 						pathBuilder.WriteString(".")
 						pathBuilder.WriteString(tokens[j+1].value)
 						j += 2
@@ -172,6 +416,7 @@ func extractVariablesFromExpression(expr string) []variableCandidate {
 						j = len(tokens)
 						continue
 					}
+					receiverLen = pathBuilder.Len()
 					pathBuilder.WriteString(buildBracketNotation(tokens[j : closing+1]))
 					j = closing + 1
 					continue
@@ -181,12 +426,12 @@ func extractVariablesFromExpression(expr string) []variableCandidate {
 			break
 		}
 
-		// Skip if next token is '(' (function call)
+		// A function call needs only its receiver, as in helpers.upper(x);
+		// a bare function such as range(3) comes from pongo2's globals.
+		fullPath := pathBuilder.String()
 		if j < len(tokens) && tokens[j].typ == tokenSymbol && tokens[j].value == "(" {
-			continue
+			fullPath = fullPath[:receiverLen]
 		}
-
-		fullPath := pathBuilder.String()
 		if fullPath == "" {
 			continue
 		}
@@ -237,7 +482,7 @@ func tokenize(expr string) []token {
 			}
 			tokens = append(tokens, token{typ: tokenString, value: expr[start:i]})
 		default:
-			tokens = append(tokens, token{typ: tokenSymbol, value: string(expr[i])})
+			tokens = append(tokens, token{typ: tokenSymbol, value: expr[i : i+1]})
 			i++
 		}
 	}
@@ -0,0 +1,30 @@
+package renderfs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/your-org/renderfs"
+)
+
+func TestExtractVariables(t *testing.T) {
+	cases := map[string][]string{
+		"plain text with { braces }":                                                           nil,
+		"{# just a comment #}":                                                                 nil,
+		"{{ name }} and {{ name|upper }}":                                                      {"name"},
+		"{% if params.enabled %}{{ user.email }}{% endif %}":                                   {"params.enabled", "user.email"},
+		"{% for item in items %}{{ loop.index }}{% endfor %}":                                  {"items"},
+		"{% for k, v in m %}{{ k }}={{ v.name }}{% endfor %}":                                  {"m"},
+		"{% for item in items %}{{ item }}{% endfor %}{{ item.name }}":                         {"item.name", "items"},
+		"{% for x in x.children %}{% for y in x.kids %}{{ y }}{% endfor %}{{ y }}{% endfor %}": {"x.children", "y"},
+		"{{ helpers.Upper(name) }}":                                                            {"helpers", "name"},
+		"{{ site.helpers.Join(tags, \", \") }}":                                                {"site.helpers", "tags"},
+		"{{ range(3) }}":                                                                       nil,
+	}
+
+	for tpl, want := range cases {
+		if got := renderfs.ExtractVariables(tpl); !reflect.DeepEqual(got, want) {
+			t.Errorf("ExtractVariables(%q) = %v, want %v", tpl, got, want)
+		}
+	}
+}
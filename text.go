@@ -0,0 +1,37 @@
+package renderfs
+
+import "bytes"
+
+// binarySniffLen is how much of a file is inspected for NUL bytes when
+// deciding whether it is binary, matching git's heuristic.
+const binarySniffLen = 8000
+
+// isBinary reports whether content looks like binary data rather than text.
+func isBinary(content []byte) bool {
+	if len(content) > binarySniffLen {
+		content = content[:binarySniffLen]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// normalizeLineEndings rewrites every line ending in content to the requested
+// style. Lone carriage returns are left alone.
+func normalizeLineEndings(content []byte, style LineEnding) []byte {
+	switch style {
+	case LF:
+		return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	case CRLF:
+		lf := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		return bytes.ReplaceAll(lf, []byte("\n"), []byte("\r\n"))
+	}
+	return content
+}
+
+// appendNewline terminates content with a newline in the given style, using
+// "\n" unless CRLF was requested.
+func appendNewline(content []byte, style LineEnding) []byte {
+	if style == CRLF {
+		return append(content, '\r', '\n')
+	}
+	return append(content, '\n')
+}
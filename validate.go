@@ -0,0 +1,52 @@
+package renderfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// structuredFormat names the format Options.ValidateStructured checks for
+// the destination name p, or "" when p is not validated.
+func structuredFormat(p string) string {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".json":
+		return "JSON"
+	case ".yaml", ".yml":
+		return "YAML"
+	}
+	return ""
+}
+
+// validateStructured reports whether data parses as the format of the
+// destination name p. Multi-document YAML streams are checked in full.
+func validateStructured(p string, data []byte) error {
+	format := structuredFormat(p)
+	var err error
+	switch format {
+	case "JSON":
+		var v interface{}
+		err = json.Unmarshal(data, &v)
+	case "YAML":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var v interface{}
+			if err = dec.Decode(&v); err != nil {
+				break
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("renderfs: rendered %s is not valid %s: %w", p, format, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package renderfs
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+)
+
+// verbatim reports whether the source entry rel matches
+// Options.VerbatimPatterns.
+func (c *copier) verbatim(rel string, isDir bool) bool {
+	return matchesEntry(c.verbatimMatcher, rel, isDir)
+}
+
+// copyVerbatim copies a file matched by Options.VerbatimPatterns byte for
+// byte. Conflict handling, OnceGlobs, manifests, and unchanged checks apply as
+// for rendered files; nothing else touches the content.
+func (c *copier) copyVerbatim(rel, renderedRel string, info fs.FileInfo) error {
+	if c.listOnly {
+		c.listed = append(c.listed, renderedRel)
+		return nil
+	}
+	if keep, err := c.keepOnceFile(renderedRel); err != nil || keep {
+		if keep {
+			c.skipped(rel, SkipOnce)
+		}
+		return err
+	}
+	proceed, err := c.handleConflict(renderedRel)
+	if err != nil {
+		return err
+	}
+	if !proceed {
+		c.skipped(rel, SkipConflict)
+		return nil
+	}
+
+	content, err := fs.ReadFile(c.source, rel)
+	if err != nil {
+		return fmt.Errorf("renderfs: read %s: %w", rel, err)
+	}
+	mode := fileMode(info)
+
+	var sum string
+	if c.manifest != nil || c.prior != nil {
+		sum = checksum(content)
+	}
+	if c.manifest != nil {
+		c.manifest[renderedRel] = sum
+	}
+	if c.unchangedSincePrior(renderedRel, sum) ||
+		(c.opts.SkipUnchanged && destinationUnchanged(c.dest, renderedRel, content, mode)) {
+		c.result.SkippedUnchanged++
+		c.skipped(rel, SkipUnchanged)
+		return nil
+	}
+
+	return c.writeFile(rel, renderedRel, mode, bytes.NewReader(content))
+}
@@ -0,0 +1,72 @@
+package writers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/your-org/renderfs"
+)
+
+// CopyDir renders the directory tree at srcDir into destPath on the local
+// filesystem. It is a convenience over renderfs.Copy with os.DirFS and an
+// OSWriter, and refuses to run when the two directories are the same or one
+// contains the other once symlinks are resolved.
+func CopyDir(srcDir, destPath string, opts renderfs.Options) error {
+	srcAbs, err := filepath.Abs(srcDir)
+	if err != nil {
+		return fmt.Errorf("renderfs: resolve source %s: %w", srcDir, err)
+	}
+	writer, err := NewOSWriter(destPath)
+	if err != nil {
+		return fmt.Errorf("renderfs: resolve destination %s: %w", destPath, err)
+	}
+	srcReal, err := realPath(srcAbs)
+	if err != nil {
+		return fmt.Errorf("renderfs: resolve source %s: %w", srcDir, err)
+	}
+	destReal, err := realPath(writer.DestDir)
+	if err != nil {
+		return fmt.Errorf("renderfs: resolve destination %s: %w", destPath, err)
+	}
+	if within(srcReal, destReal) {
+		// The walk would pick up files as they are written.
+		return fmt.Errorf("renderfs: destination %s is inside source %s and would overlap it", writer.DestDir, srcAbs)
+	}
+	if within(destReal, srcReal) {
+		return fmt.Errorf("renderfs: source %s and destination %s overlap", srcAbs, writer.DestDir)
+	}
+	return renderfs.Copy(os.DirFS(srcAbs), writer, opts)
+}
+
+// realPath resolves symlinks in an absolute path. Trailing components that do
+// not exist yet (such as a destination Copy will create) are kept verbatim
+// beneath the resolved existing ancestor.
+func realPath(abs string) (string, error) {
+	var rest []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			for i := len(rest) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, rest[i])
+			}
+			return resolved, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) || filepath.Dir(dir) == dir {
+			return "", err
+		}
+		rest = append(rest, filepath.Base(dir))
+	}
+}
+
+// within reports whether path is root or lies beneath it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
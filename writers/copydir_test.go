@@ -0,0 +1,74 @@
+package writers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/your-org/renderfs"
+)
+
+func TestCopyDirRendersTree(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "{{ name }}"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "{{ name }}", "main.go.tmpl"), []byte("package {{ name }}"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "out")
+	opts := renderfs.Options{Context: pongo2.Context{"name": "app"}}
+	if err := CopyDir(src, dest, opts); err != nil {
+		t.Fatalf("CopyDir: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dest, "app", "main.go"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(content) != "package app" {
+		t.Fatalf("unexpected content: %q", content)
+	}
+}
+
+func TestCopyDirRejectsOverlap(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "output")
+
+	cases := []struct{ src, dest string }{
+		{root, root},
+		{root, nested},
+		{nested, root},
+	}
+	for _, tc := range cases {
+		err := CopyDir(tc.src, tc.dest, renderfs.Options{})
+		if err == nil || !strings.Contains(err.Error(), "overlap") {
+			t.Errorf("CopyDir(%s, %s): expected overlap error, got %v", tc.src, tc.dest, err)
+		}
+	}
+	if _, err := os.Stat(nested); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be written, stat returned %v", err)
+	}
+}
+
+func TestCopyDirRejectsDestinationNestedThroughSymlink(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	alias := filepath.Join(t.TempDir(), "alias")
+	if err := os.Symlink(src, alias); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	err := CopyDir(src, filepath.Join(alias, "output"), renderfs.Options{})
+	if err == nil || !strings.Contains(err.Error(), "inside source") {
+		t.Fatalf("expected nested destination to be rejected, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "output")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be written, stat returned %v", err)
+	}
+}
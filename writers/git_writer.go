@@ -0,0 +1,90 @@
+package writers
+
+import (
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+
+	"github.com/your-org/renderfs"
+)
+
+// GitWriter wraps an OSWriter and records every file and symlink it writes, so
+// a caller scaffolding into a repository knows exactly what to stage. It does
+// not run git itself.
+type GitWriter struct {
+	*OSWriter
+
+	mu      sync.Mutex
+	written map[string]struct{}
+}
+
+// NewGitWriter returns a GitWriter that writes through inner.
+func NewGitWriter(inner *OSWriter) *GitWriter {
+	return &GitWriter{OSWriter: inner, written: make(map[string]struct{})}
+}
+
+// CreateFile creates the file and records its path once the handle is closed
+// successfully.
+func (w *GitWriter) CreateFile(path string, perm fs.FileMode) (io.WriteCloser, error) {
+	handle, err := w.OSWriter.CreateFile(path, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &gitFileHandle{WriteCloser: handle, writer: w, path: path}, nil
+}
+
+// AppendFile appends to the file and records its path once the handle is
+// closed successfully.
+func (w *GitWriter) AppendFile(path string, perm fs.FileMode) (io.WriteCloser, error) {
+	handle, err := w.OSWriter.AppendFile(path, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &gitFileHandle{WriteCloser: handle, writer: w, path: path}, nil
+}
+
+// Symlink creates the link and records its path.
+func (w *GitWriter) Symlink(oldname, newname string) error {
+	if err := w.OSWriter.Symlink(oldname, newname); err != nil {
+		return err
+	}
+	w.record(newname)
+	return nil
+}
+
+// StagedPaths returns the sorted, slash-separated paths written so far,
+// relative to DestDir, ready to pass to `git add` run from DestDir.
+func (w *GitWriter) StagedPaths() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	paths := make([]string, 0, len(w.written))
+	for p := range w.written {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (w *GitWriter) record(p string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written[normalizePath(p)] = struct{}{}
+}
+
+type gitFileHandle struct {
+	io.WriteCloser
+	writer *GitWriter
+	path   string
+}
+
+func (h *gitFileHandle) Close() error {
+	if err := h.WriteCloser.Close(); err != nil {
+		return err
+	}
+	h.writer.record(h.path)
+	return nil
+}
+
+var _ renderfs.Writer = (*GitWriter)(nil)
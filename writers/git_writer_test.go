@@ -0,0 +1,73 @@
+package writers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/your-org/renderfs"
+)
+
+func TestGitWriterStagedPaths(t *testing.T) {
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dest, "existing.txt"), []byte("keep"), 0o644); err != nil {
+		t.Fatalf("write existing: %v", err)
+	}
+
+	inner, err := NewOSWriter(dest)
+	if err != nil {
+		t.Fatalf("NewOSWriter: %v", err)
+	}
+	writer := NewGitWriter(inner)
+
+	source := fstest.MapFS{
+		"existing.txt":    {Data: []byte("replaced?")},
+		"cmd/main.go":     {Data: []byte("package main")},
+		"docs/README.md":  {Data: []byte("docs")},
+		"docs/empty/.dir": {Data: []byte("")},
+	}
+	if err := renderfs.Copy(source, writer, renderfs.Options{OnConflict: renderfs.Skip}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	want := []string{"cmd/main.go", "docs/README.md", "docs/empty/.dir"}
+	if got := writer.StagedPaths(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("StagedPaths() = %v, want %v", got, want)
+	}
+}
+
+func TestGitWriterTransactional(t *testing.T) {
+	inner, err := NewOSWriter(filepath.Join(t.TempDir(), "repo"))
+	if err != nil {
+		t.Fatalf("NewOSWriter: %v", err)
+	}
+	writer := NewGitWriter(inner)
+	source := fstest.MapFS{
+		"a.txt":   {Data: []byte("a")},
+		"b/b.txt": {Data: []byte("b")},
+	}
+
+	opts := renderfs.Options{
+		Transactional: true,
+		AfterCopy:     func(renderfs.CopyResult, bool) error { return os.ErrPermission },
+	}
+	if err := renderfs.Copy(source, writer, opts); err == nil {
+		t.Fatalf("expected AfterCopy failure")
+	}
+	if paths := writer.StagedPaths(); len(paths) != 0 {
+		t.Fatalf("expected nothing recorded after rollback, got %v", paths)
+	}
+
+	opts.AfterCopy = nil
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got, want := writer.StagedPaths(), []string{"a.txt", "b/b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("StagedPaths() = %v, want %v", got, want)
+	}
+	if data, err := os.ReadFile(filepath.Join(inner.DestDir, "b/b.txt")); err != nil || string(data) != "b" {
+		t.Fatalf("b/b.txt = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,133 @@
+package writers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing/fstest"
+
+	"github.com/your-org/renderfs"
+)
+
+// MapFSWriter implements renderfs.Writer by storing output in an
+// fstest.MapFS, so tests can run fstest helpers on the result or feed it to
+// another Copy without converting it first.
+type MapFSWriter struct {
+	mu    sync.RWMutex
+	files fstest.MapFS
+}
+
+// NewMapFSWriter constructs an empty MapFSWriter.
+func NewMapFSWriter() *MapFSWriter {
+	return &MapFSWriter{files: make(fstest.MapFS)}
+}
+
+// MapFS returns a copy of the stored output. Directories implied by stored
+// paths are synthesized by fstest.MapFS; later writes are not reflected.
+func (w *MapFSWriter) MapFS() fstest.MapFS {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	out := make(fstest.MapFS, len(w.files))
+	for p, f := range w.files {
+		clone := *f
+		clone.Data = append([]byte(nil), f.Data...)
+		out[p] = &clone
+	}
+	return out
+}
+
+// MkdirAll records a directory entry with the given permissions. Missing
+// parents are left implicit.
+func (w *MapFSWriter) MkdirAll(p string, perm fs.FileMode) error {
+	p = normalizePath(p)
+	if p == "." {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.files[p] = &fstest.MapFile{Mode: fs.ModeDir | perm.Perm()}
+	return nil
+}
+
+// CreateFile returns a handle whose content replaces any entry at p once it
+// is closed.
+func (w *MapFSWriter) CreateFile(p string, perm fs.FileMode) (io.WriteCloser, error) {
+	return &mapFSFileWriteCloser{writer: w, path: normalizePath(p), mode: perm}, nil
+}
+
+// Symlink records a link entry whose data is the target, as fstest.MapFS
+// expects.
+func (w *MapFSWriter) Symlink(oldname, newname string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.files[normalizePath(newname)] = &fstest.MapFile{Data: []byte(oldname), Mode: fs.ModeSymlink | 0o777}
+	return nil
+}
+
+// Lstat reports metadata for conflict detection without following links.
+func (w *MapFSWriter) Lstat(p string) (fs.FileInfo, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.files.Lstat(normalizePath(p))
+}
+
+// ReadFile returns a copy of the stored file contents.
+func (w *MapFSWriter) ReadFile(p string) ([]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	f, ok := w.files[normalizePath(p)]
+	if !ok || !f.Mode.IsRegular() {
+		return nil, fs.ErrNotExist
+	}
+	return append([]byte(nil), f.Data...), nil
+}
+
+// Remove deletes a stored file, symlink, or empty directory.
+func (w *MapFSWriter) Remove(p string) error {
+	p = normalizePath(p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	prefix := p + "/"
+	for k := range w.files {
+		if strings.HasPrefix(k, prefix) {
+			return &fs.PathError{Op: "remove", Path: p, Err: errors.New("directory not empty")}
+		}
+	}
+	if _, ok := w.files[p]; !ok {
+		return &fs.PathError{Op: "remove", Path: p, Err: fs.ErrNotExist}
+	}
+	delete(w.files, p)
+	return nil
+}
+
+type mapFSFileWriteCloser struct {
+	writer *MapFSWriter
+	path   string
+	mode   fs.FileMode
+	buf    bytes.Buffer
+}
+
+func (wc *mapFSFileWriteCloser) Write(p []byte) (int, error) {
+	return wc.buf.Write(p)
+}
+
+func (wc *mapFSFileWriteCloser) Close() error {
+	wc.writer.mu.Lock()
+	defer wc.writer.mu.Unlock()
+
+	wc.writer.files[wc.path] = &fstest.MapFile{Data: wc.buf.Bytes(), Mode: wc.mode}
+	return nil
+}
+
+var _ renderfs.Writer = (*MapFSWriter)(nil)
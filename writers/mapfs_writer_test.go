@@ -0,0 +1,56 @@
+package writers
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+
+	"github.com/your-org/renderfs"
+)
+
+func TestMapFSWriterCopy(t *testing.T) {
+	source := fstest.MapFS{
+		"README.md.tmpl":         {Data: []byte("# {{ name }}\n")},
+		"bin/run.sh":             {Data: []byte("#!/bin/sh\n"), Mode: 0o755},
+		"src/{{ name }}/main.go": {Data: []byte("package {{ name }}\n")},
+		"docs":                   {Mode: fs.ModeDir | 0o750},
+	}
+	opts := renderfs.Options{Context: pongo2.Context{"name": "demo"}}
+
+	writer := NewMapFSWriter()
+	if err := renderfs.Copy(source, writer, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	out := writer.MapFS()
+	if err := fstest.TestFS(out, "README.md", "bin/run.sh", "src/demo/main.go", "docs"); err != nil {
+		t.Fatalf("TestFS: %v", err)
+	}
+	if got := string(out["src/demo/main.go"].Data); got != "package demo\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+	if mode := out["bin/run.sh"].Mode; mode != 0o755 {
+		t.Fatalf("expected mode 0755, got %v", mode)
+	}
+	if mode := out["docs"].Mode; mode != fs.ModeDir|0o750 {
+		t.Fatalf("expected directory mode, got %v", mode)
+	}
+
+	// The result feeds another copy directly.
+	again := NewMapFSWriter()
+	if err := renderfs.Copy(out, again, opts); err != nil {
+		t.Fatalf("second Copy failed: %v", err)
+	}
+	if got := string(again.MapFS()["README.md"].Data); got != "# demo\n" {
+		t.Fatalf("unexpected content after second copy %q", got)
+	}
+
+	// Conflict detection sees what was written.
+	opts.OnConflict = renderfs.Fail
+	err := renderfs.Copy(source, writer, opts)
+	if err == nil {
+		t.Fatalf("expected conflict error")
+	}
+}
@@ -0,0 +1,33 @@
+package writers
+
+import (
+	"io/fs"
+
+	"github.com/your-org/renderfs/internal/memfs"
+)
+
+// FS returns a read-only snapshot of the stored output as an fs.FS, so the
+// result of one Copy can feed another. Later writes are not reflected.
+// Directories implied by stored paths are included.
+//
+// Open, Stat, and ReadFile follow symlinks whose targets resolve within the
+// snapshot; a link whose target is missing, absolute, or outside the root
+// reports fs.ErrNotExist when opened. The view also implements
+// fs.ReadLinkFS, whose Lstat and ReadLink describe links themselves, and
+// ReadDir lists links as entries of type fs.ModeSymlink.
+func (w *MemoryWriter) FS() fs.FS {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	m := memfs.New()
+	for p, mode := range w.dirs {
+		m.AddDir(p, mode)
+	}
+	for p, f := range w.files {
+		m.AddFile(p, f.Mode, append([]byte(nil), f.Content.Bytes()...))
+	}
+	for p, link := range w.symlinks {
+		m.AddSymlink(p, link.Target)
+	}
+	return m
+}
@@ -0,0 +1,137 @@
+package writers
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+
+	"github.com/your-org/renderfs"
+)
+
+func writeMemoryFile(t *testing.T, w *MemoryWriter, p, content string) {
+	t.Helper()
+	handle, err := w.CreateFile(p, 0o644)
+	if err != nil {
+		t.Fatalf("CreateFile(%s): %v", p, err)
+	}
+	if _, err := handle.Write([]byte(content)); err != nil {
+		t.Fatalf("Write(%s): %v", p, err)
+	}
+	handle.Close()
+}
+
+func TestMemoryWriterFSSymlinks(t *testing.T) {
+	writer := NewMemoryWriter()
+	writeMemoryFile(t, writer, "config/app.yaml", "name: {{ name }}\n")
+	if err := writer.Symlink("config/app.yaml", "current.yaml"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if err := writer.Symlink("../config", "links/config"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if err := writer.Symlink("missing.yaml", "broken.yaml"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	fsys := writer.FS()
+
+	for _, p := range []string{"current.yaml", "links/config/app.yaml"} {
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil || string(data) != "name: {{ name }}\n" {
+			t.Fatalf("ReadFile(%s) = %q, %v", p, data, err)
+		}
+	}
+	info, err := fs.Stat(fsys, "current.yaml")
+	if err != nil || !info.Mode().IsRegular() || info.Name() != "current.yaml" {
+		t.Fatalf("Stat(current.yaml) = %v, %v", info, err)
+	}
+
+	info, err = fs.Lstat(fsys, "current.yaml")
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("Lstat(current.yaml) = %v, %v", info, err)
+	}
+	if target, err := fs.ReadLink(fsys, "current.yaml"); err != nil || target != "config/app.yaml" {
+		t.Fatalf("ReadLink = %q, %v", target, err)
+	}
+
+	if _, err := fsys.Open("broken.yaml"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected broken link to report ErrNotExist, got %v", err)
+	}
+	if info, err := fs.Lstat(fsys, "broken.yaml"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("expected broken link to be visible to Lstat, got %v, %v", info, err)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+		if entry.Name() == "current.yaml" && entry.Type() != fs.ModeSymlink {
+			t.Fatalf("expected current.yaml to be listed as a symlink, got %v", entry.Type())
+		}
+	}
+	if want := "broken.yaml config current.yaml links"; strings.Join(names, " ") != want {
+		t.Fatalf("ReadDir(.) = %v, want %s", names, want)
+	}
+}
+
+func TestMemoryWriterFSChainedCopy(t *testing.T) {
+	first := NewMemoryWriter()
+	writeMemoryFile(t, first, "config/app.yaml", "name: {{ name }}\n")
+	if err := first.Symlink("config/app.yaml", "current.yaml"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	second := NewMemoryWriter()
+	opts := renderfs.Options{Context: pongo2.Context{"name": "demo"}}
+	if err := renderfs.Copy(first.FS(), second, opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(second.Contents()["config/app.yaml"]); got != "name: demo\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+	info, err := second.Lstat("current.yaml")
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("expected symlink to be copied, got %v, %v", info, err)
+	}
+}
+
+func TestMemoryWriterFSConformance(t *testing.T) {
+	writer := NewMemoryWriter()
+	if err := writer.MkdirAll("empty", 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	writeMemoryFile(t, writer, "README.md", "# demo\n")
+	writeMemoryFile(t, writer, "src/main.go", "package main\n")
+	writeMemoryFile(t, writer, "src/internal/util/util.go", "package util\n")
+	writeMemoryFile(t, writer, "assets/blank.txt", "")
+	if err := writer.Symlink("src/main.go", "main.go"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if err := writer.Symlink("../src", "assets/src"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	// TestFS also checks ReadDir, ReadFile, Stat, and Lstat against Open,
+	// since the view implements their optional interfaces.
+	if err := fstest.TestFS(writer.FS(),
+		"README.md",
+		"empty",
+		"src/main.go",
+		"src/internal/util/util.go",
+		"assets/blank.txt",
+		"main.go",
+		"assets/src",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(NewMemoryWriter().FS()); err != nil {
+		t.Fatalf("empty writer: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package writers
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"io/fs"
 	"path"
@@ -90,6 +91,31 @@ func (w *MemoryWriter) CreateFile(p string, perm fs.FileMode) (io.WriteCloser, e
 	return &memoryFileWriteCloser{buf: file.Content}, nil
 }
 
+// AppendFile returns a handle that appends to the stored file at p, creating
+// it when missing. The stored content is copied first, so earlier snapshots
+// and stages sharing the entry are unaffected.
+func (w *MemoryWriter) AppendFile(p string, perm fs.FileMode) (io.WriteCloser, error) {
+	p = normalizePath(p)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	content := &bytes.Buffer{}
+	if existing, ok := w.files[p]; ok {
+		content.Write(existing.Content.Bytes())
+	}
+	w.files[p] = &MemoryFile{Content: content, Mode: perm}
+	delete(w.symlinks, p)
+
+	dir := path.Dir(p)
+	if dir != "." {
+		if _, ok := w.dirs[dir]; !ok {
+			w.dirs[dir] = 0o755
+		}
+	}
+
+	return &memoryFileWriteCloser{buf: content}, nil
+}
+
 // Symlink records an in-memory symlink.
 func (w *MemoryWriter) Symlink(oldname, newname string) error {
 	newname = normalizePath(newname)
@@ -130,6 +156,62 @@ func (w *MemoryWriter) Lstat(p string) (fs.FileInfo, error) {
 	return nil, fs.ErrNotExist
 }
 
+// ReadFile returns a copy of the stored file contents.
+func (w *MemoryWriter) ReadFile(p string) ([]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if f, ok := w.files[normalizePath(p)]; ok {
+		return append([]byte(nil), f.Content.Bytes()...), nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+// Remove deletes a stored file, symlink, or empty directory.
+func (w *MemoryWriter) Remove(p string) error {
+	p = normalizePath(p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.files[p]; ok {
+		delete(w.files, p)
+		return nil
+	}
+	if _, ok := w.symlinks[p]; ok {
+		delete(w.symlinks, p)
+		return nil
+	}
+	if _, ok := w.dirs[p]; ok {
+		if w.hasChildren(p) {
+			return &fs.PathError{Op: "remove", Path: p, Err: errors.New("directory not empty")}
+		}
+		delete(w.dirs, p)
+		return nil
+	}
+	return &fs.PathError{Op: "remove", Path: p, Err: fs.ErrNotExist}
+}
+
+func (w *MemoryWriter) hasChildren(dir string) bool {
+	prefix := dir + "/"
+	for k := range w.files {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	for k := range w.symlinks {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	for k := range w.dirs {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // Contents returns a snapshot copy of the stored files for inspection.
 func (w *MemoryWriter) Contents() map[string][]byte {
 	w.mu.RLock()
@@ -1,6 +1,7 @@
 package writers
 
 import (
+	"errors"
 	"io/fs"
 	"testing"
 )
@@ -59,3 +60,26 @@ func TestMemoryWriterSymlink(t *testing.T) {
 		t.Fatalf("expected symlink mode, got %v", info.Mode())
 	}
 }
+
+func TestMemoryWriterRemove(t *testing.T) {
+	writer := NewMemoryWriter()
+	if err := writer.Symlink("target", "dir/link"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	if err := writer.Remove("dir"); err == nil {
+		t.Fatalf("expected removing a non-empty directory to fail")
+	}
+	if err := writer.Remove("dir/link"); err != nil {
+		t.Fatalf("Remove link: %v", err)
+	}
+	if _, err := writer.Lstat("dir/link"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected link to be gone, got %v", err)
+	}
+	if err := writer.Remove("dir"); err != nil {
+		t.Fatalf("Remove empty dir: %v", err)
+	}
+	if err := writer.Remove("dir"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+}
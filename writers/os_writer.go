@@ -1,10 +1,13 @@
 package writers
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/your-org/renderfs"
 )
@@ -12,22 +15,105 @@ import (
 // OSWriter implements renderfs.Writer for the local filesystem rooted at DestDir.
 type OSWriter struct {
 	DestDir string
+
+	parentDirMode     fs.FileMode
+	restrictSymlinks  bool
+	ignoreChmodErrors bool
+
+	// chmodFunc replaces os.Chmod in tests.
+	chmodFunc func(name string, mode fs.FileMode) error
+}
+
+// OSWriterOptions configures an OSWriter created with NewOSWriterWithOptions.
+type OSWriterOptions struct {
+	// ParentDirMode is applied to directories that CreateFile and Symlink
+	// create implicitly. Defaults to 0o755.
+	ParentDirMode fs.FileMode
+
+	// AllowExternalSymlinks permits symlinks whose targets are absolute or
+	// resolve outside DestDir.
+	AllowExternalSymlinks bool
+
+	// IgnoreChmodErrors makes setting permissions best-effort, for
+	// filesystems such as FAT or some network mounts that reject chmod.
+	// Files and directories are still created, with whatever permissions
+	// the filesystem gives them.
+	IgnoreChmodErrors bool
 }
 
 // NewOSWriter constructs an OSWriter rooted at destDir. The destination path
 // is resolved to an absolute path to prevent directory traversal.
 func NewOSWriter(destDir string) (*OSWriter, error) {
+	return NewOSWriterWithOptions(destDir, OSWriterOptions{AllowExternalSymlinks: true})
+}
+
+// NewOSWriterWithOptions constructs an OSWriter rooted at destDir using the
+// provided options.
+func NewOSWriterWithOptions(destDir string, opts OSWriterOptions) (*OSWriter, error) {
 	abs, err := filepath.Abs(destDir)
 	if err != nil {
 		return nil, err
 	}
-	return &OSWriter{DestDir: abs}, nil
+	return &OSWriter{
+		DestDir:           abs,
+		parentDirMode:     opts.ParentDirMode.Perm(),
+		restrictSymlinks:  !opts.AllowExternalSymlinks,
+		ignoreChmodErrors: opts.IgnoreChmodErrors,
+	}, nil
+}
+
+// chmod sets the permissions of name, ignoring failures when the writer was
+// built with IgnoreChmodErrors.
+func (w *OSWriter) chmod(name string, mode fs.FileMode) error {
+	chmod := w.chmodFunc
+	if chmod == nil {
+		chmod = os.Chmod
+	}
+	if err := chmod(name, mode); err != nil && !w.ignoreChmodErrors {
+		return err
+	}
+	return nil
 }
 
 func (w *OSWriter) join(path string) string {
 	return filepath.Join(w.DestDir, filepath.FromSlash(path))
 }
 
+// mkdirParents creates dir and any missing ancestors. Only directories created
+// here receive the parent mode; existing directories keep their permissions.
+func (w *OSWriter) mkdirParents(dir string) error {
+	mode := w.parentDirMode
+	if mode == 0 {
+		mode = 0o755
+	}
+
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	for _, d := range missing {
+		if err := w.chmod(d, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // MkdirAll creates directories on disk and ensures the final directory has the
 // requested permissions.
 func (w *OSWriter) MkdirAll(path string, perm fs.FileMode) error {
@@ -35,22 +121,47 @@ func (w *OSWriter) MkdirAll(path string, perm fs.FileMode) error {
 	if err := os.MkdirAll(full, perm); err != nil {
 		return err
 	}
-	return os.Chmod(full, perm.Perm())
+	return w.chmod(full, perm.Perm())
 }
 
 // CreateFile opens a file for writing, creating any missing parent directories.
+// Parents are only checked when the open reports them missing, so writing
+// into an existing directory costs no extra stat.
 func (w *OSWriter) CreateFile(path string, perm fs.FileMode) (io.WriteCloser, error) {
 	full := w.join(path)
-	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+	f, err := os.OpenFile(full, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := w.mkdirParents(filepath.Dir(full)); err != nil {
+			return nil, err
+		}
+		f, err = os.OpenFile(full, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.chmod(full, perm.Perm()); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// AppendFile opens a file for appending, creating it and any missing parent
+// directories when needed.
+func (w *OSWriter) AppendFile(path string, perm fs.FileMode) (io.WriteCloser, error) {
+	full := w.join(path)
+	if err := w.mkdirParents(filepath.Dir(full)); err != nil {
 		return nil, err
 	}
 
-	f, err := os.OpenFile(full, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	f, err := os.OpenFile(full, os.O_CREATE|os.O_WRONLY|os.O_APPEND, perm)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := os.Chmod(full, perm.Perm()); err != nil {
+	if err := w.chmod(full, perm.Perm()); err != nil {
 		_ = f.Close()
 		return nil, err
 	}
@@ -58,19 +169,48 @@ func (w *OSWriter) CreateFile(path string, perm fs.FileMode) (io.WriteCloser, er
 	return f, nil
 }
 
-// Symlink creates a symbolic link within DestDir.
+// Symlink creates a symbolic link within DestDir. When the writer was built
+// without AllowExternalSymlinks, targets that are absolute or resolve outside
+// DestDir are rejected.
 func (w *OSWriter) Symlink(oldname, newname string) error {
 	full := w.join(newname)
-	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+	if w.restrictSymlinks && !w.withinDest(filepath.Dir(full), oldname) {
+		return fmt.Errorf("symlink target %q escapes %s", oldname, w.DestDir)
+	}
+	if err := w.mkdirParents(filepath.Dir(full)); err != nil {
 		return err
 	}
 	return os.Symlink(oldname, full)
 }
 
+func (w *OSWriter) withinDest(linkDir, target string) bool {
+	if filepath.IsAbs(target) {
+		return false
+	}
+	resolved := filepath.Join(linkDir, filepath.FromSlash(target))
+	rel, err := filepath.Rel(w.DestDir, resolved)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // Lstat reports information about a path relative to DestDir. It allows Copy to
 // implement conflict handling semantics.
 func (w *OSWriter) Lstat(path string) (fs.FileInfo, error) {
 	return os.Lstat(w.join(path))
 }
 
+// ReadFile returns the contents of a file relative to DestDir. It allows Copy
+// to detect unchanged files.
+func (w *OSWriter) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(w.join(path))
+}
+
+// Remove deletes a file, symlink, or empty directory relative to DestDir.
+// Symlinks are removed themselves, never their targets.
+func (w *OSWriter) Remove(path string) error {
+	return os.Remove(w.join(path))
+}
+
 var _ renderfs.Writer = (*OSWriter)(nil)
@@ -1,9 +1,16 @@
 package writers
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+
+	"github.com/your-org/renderfs"
 )
 
 func TestOSWriterCreatesDirectoriesAndFiles(t *testing.T) {
@@ -72,3 +79,149 @@ func TestOSWriterSymlink(t *testing.T) {
 		t.Fatalf("unexpected link target: %q", target)
 	}
 }
+
+func TestOSWriterWithOptionsParentDirMode(t *testing.T) {
+	dest := t.TempDir()
+	writer, err := NewOSWriterWithOptions(dest, OSWriterOptions{ParentDirMode: 0o770})
+	if err != nil {
+		t.Fatalf("NewOSWriterWithOptions: %v", err)
+	}
+
+	handle, err := writer.CreateFile("a/b/file.txt", 0o644)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	if err := handle.Close(); err != nil {
+		t.Fatalf("close file: %v", err)
+	}
+
+	for _, dir := range []string{"a", "a/b"} {
+		info, err := os.Stat(filepath.Join(dest, dir))
+		if err != nil {
+			t.Fatalf("stat %s: %v", dir, err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o770 {
+			t.Fatalf("expected %s perm 770, got %o", dir, perm)
+		}
+	}
+
+	destInfo, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("stat dest: %v", err)
+	}
+	if perm := destInfo.Mode().Perm(); perm == 0o770 {
+		t.Fatalf("expected existing destination mode to be left alone")
+	}
+}
+
+func TestOSWriterIgnoreChmodErrors(t *testing.T) {
+	source := fstest.MapFS{
+		"bin":        {Mode: fs.ModeDir | 0o755},
+		"bin/run.sh": {Data: []byte("#!/bin/sh\n"), Mode: 0o755},
+	}
+	unsupported := func(string, fs.FileMode) error { return errors.ErrUnsupported }
+
+	strict, err := NewOSWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewOSWriter: %v", err)
+	}
+	strict.chmodFunc = unsupported
+	if err := renderfs.Copy(source, strict, renderfs.Options{}); err == nil || !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("expected chmod failure to abort the copy, got %v", err)
+	}
+
+	dest := t.TempDir()
+	lenient, err := NewOSWriterWithOptions(dest, OSWriterOptions{IgnoreChmodErrors: true})
+	if err != nil {
+		t.Fatalf("NewOSWriterWithOptions: %v", err)
+	}
+	lenient.chmodFunc = unsupported
+	if err := renderfs.Copy(source, lenient, renderfs.Options{}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "bin", "run.sh"))
+	if err != nil || !strings.HasPrefix(string(data), "#!/bin/sh") {
+		t.Fatalf("expected script to be written, got %q (%v)", data, err)
+	}
+}
+
+func TestOSWriterWithOptionsRejectsExternalSymlinks(t *testing.T) {
+	dest := t.TempDir()
+	writer, err := NewOSWriterWithOptions(dest, OSWriterOptions{})
+	if err != nil {
+		t.Fatalf("NewOSWriterWithOptions: %v", err)
+	}
+
+	if err := writer.Symlink("../outside", "link"); err == nil {
+		t.Fatalf("expected escaping symlink to be rejected")
+	}
+	if err := writer.Symlink("/etc/passwd", "abs"); err == nil {
+		t.Fatalf("expected absolute symlink to be rejected")
+	}
+	if err := writer.Symlink("../target.txt", "nested/link"); err != nil {
+		t.Fatalf("expected internal symlink to be allowed: %v", err)
+	}
+}
+
+func TestOSWriterCreateFileSetsExactMode(t *testing.T) {
+	dest := t.TempDir()
+	writer, err := NewOSWriter(dest)
+	if err != nil {
+		t.Fatalf("NewOSWriter: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "existing.txt"), []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, perm := range map[string]fs.FileMode{
+		"existing.txt":   0o644,
+		"nested/run.sh":  0o755,
+		"nested/open.sh": 0o777,
+	} {
+		handle, err := writer.CreateFile(name, perm)
+		if err != nil {
+			t.Fatalf("CreateFile(%s): %v", name, err)
+		}
+		if _, err := handle.Write([]byte("new")); err != nil {
+			t.Fatalf("Write(%s): %v", name, err)
+		}
+		if err := handle.Close(); err != nil {
+			t.Fatalf("Close(%s): %v", name, err)
+		}
+		info, err := os.Stat(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != perm {
+			t.Fatalf("expected %s to have mode %v, got %v", name, perm, info.Mode().Perm())
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "existing.txt"))
+	if err != nil || string(data) != "new" {
+		t.Fatalf("expected existing file to be truncated, got %q (%v)", data, err)
+	}
+}
+
+func BenchmarkOSWriterTinyFiles(b *testing.B) {
+	source := fstest.MapFS{}
+	for i := 0; i < 20; i++ {
+		dir := fmt.Sprintf("pkg%d", i)
+		source[dir] = &fstest.MapFile{Mode: fs.ModeDir | 0o755}
+		for j := 0; j < 100; j++ {
+			source[fmt.Sprintf("%s/file%d.txt", dir, j)] = &fstest.MapFile{Data: []byte("x\n"), Mode: 0o644}
+		}
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		writer, err := NewOSWriter(b.TempDir())
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		if err := renderfs.Copy(source, writer, renderfs.Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
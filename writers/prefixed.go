@@ -0,0 +1,97 @@
+package writers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/your-org/renderfs"
+)
+
+type lstatWriter interface {
+	Lstat(path string) (fs.FileInfo, error)
+}
+
+type readFileWriter interface {
+	ReadFile(path string) ([]byte, error)
+}
+
+type removeWriter interface {
+	Remove(path string) error
+}
+
+type appendWriter interface {
+	AppendFile(path string, perm fs.FileMode) (io.WriteCloser, error)
+}
+
+// Prefixed returns a Writer that places every path under prefix before
+// delegating to inner. It allows several template trees to share one
+// destination, each in its own subdirectory. Symlink targets are passed
+// through untouched so relative links keep working inside the prefix.
+func Prefixed(inner renderfs.Writer, prefix string) renderfs.Writer {
+	return &prefixedWriter{inner: inner, prefix: normalizePath(prefix)}
+}
+
+type prefixedWriter struct {
+	inner  renderfs.Writer
+	prefix string
+}
+
+func (w *prefixedWriter) join(p string) string {
+	return path.Join(w.prefix, normalizePath(p))
+}
+
+// MkdirAll creates the prefixed directory in the inner writer.
+func (w *prefixedWriter) MkdirAll(p string, perm fs.FileMode) error {
+	return w.inner.MkdirAll(w.join(p), perm)
+}
+
+// CreateFile creates the prefixed file in the inner writer.
+func (w *prefixedWriter) CreateFile(p string, perm fs.FileMode) (io.WriteCloser, error) {
+	return w.inner.CreateFile(w.join(p), perm)
+}
+
+// AppendFile appends to the prefixed file when the inner writer supports it.
+func (w *prefixedWriter) AppendFile(p string, perm fs.FileMode) (io.WriteCloser, error) {
+	aw, ok := w.inner.(appendWriter)
+	if !ok {
+		return nil, fmt.Errorf("append %s: %w", p, errors.ErrUnsupported)
+	}
+	return aw.AppendFile(w.join(p), perm)
+}
+
+// Symlink creates the prefixed link in the inner writer.
+func (w *prefixedWriter) Symlink(oldname, newname string) error {
+	return w.inner.Symlink(oldname, w.join(newname))
+}
+
+// Lstat reports on the prefixed path. When the inner writer cannot stat,
+// it returns errors.ErrUnsupported so Copy treats the destination as one
+// without conflict detection, as it would the inner writer itself.
+func (w *prefixedWriter) Lstat(p string) (fs.FileInfo, error) {
+	sw, ok := w.inner.(lstatWriter)
+	if !ok {
+		return nil, fmt.Errorf("lstat %s: %w", p, errors.ErrUnsupported)
+	}
+	return sw.Lstat(w.join(p))
+}
+
+// ReadFile reads the prefixed path when the inner writer supports it.
+func (w *prefixedWriter) ReadFile(p string) ([]byte, error) {
+	rw, ok := w.inner.(readFileWriter)
+	if !ok {
+		return nil, fmt.Errorf("read %s: %w", p, errors.ErrUnsupported)
+	}
+	return rw.ReadFile(w.join(p))
+}
+
+// Remove deletes the prefixed path when the inner writer supports it.
+func (w *prefixedWriter) Remove(p string) error {
+	rw, ok := w.inner.(removeWriter)
+	if !ok {
+		return fmt.Errorf("remove %s: %w", p, errors.ErrUnsupported)
+	}
+	return rw.Remove(w.join(p))
+}
@@ -0,0 +1,98 @@
+package writers
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/your-org/renderfs"
+)
+
+func TestPrefixedWriterJoinsPaths(t *testing.T) {
+	inner := NewMemoryWriter()
+	writer := Prefixed(inner, "frontend")
+
+	if err := writer.MkdirAll("src", 0o750); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	handle, err := writer.CreateFile("src/app.js", 0o644)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	if _, err := handle.Write([]byte("app")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := handle.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := writer.Symlink("src/app.js", "index.js"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	if mode, ok := inner.DirMode("frontend/src"); !ok || mode != 0o750 {
+		t.Fatalf("expected prefixed dir mode 750, got %v (ok=%v)", mode, ok)
+	}
+	if got := string(inner.Contents()["frontend/src/app.js"]); got != "app" {
+		t.Fatalf("unexpected prefixed content: %q", got)
+	}
+	if _, err := inner.Lstat("frontend/index.js"); err != nil {
+		t.Fatalf("expected prefixed symlink: %v", err)
+	}
+
+	lw, ok := writer.(lstatWriter)
+	if !ok {
+		t.Fatalf("expected Prefixed to expose Lstat")
+	}
+	info, err := lw.Lstat("src/app.js")
+	if err != nil {
+		t.Fatalf("Lstat: %v", err)
+	}
+	if info.Name() != "app.js" || info.Size() != 3 {
+		t.Fatalf("unexpected Lstat result: %s (%d bytes)", info.Name(), info.Size())
+	}
+}
+
+// writeOnlyWriter exposes only the required renderfs.Writer methods, like an
+// archive writer that cannot read back what it wrote.
+type writeOnlyWriter struct {
+	inner *MemoryWriter
+}
+
+func (w writeOnlyWriter) MkdirAll(p string, perm fs.FileMode) error {
+	return w.inner.MkdirAll(p, perm)
+}
+
+func (w writeOnlyWriter) CreateFile(p string, perm fs.FileMode) (io.WriteCloser, error) {
+	return w.inner.CreateFile(p, perm)
+}
+
+func (w writeOnlyWriter) Symlink(oldname, newname string) error {
+	return w.inner.Symlink(oldname, newname)
+}
+
+func TestPrefixedWriterWithoutLstat(t *testing.T) {
+	source := fstest.MapFS{"a.txt": {Data: []byte("{{ name }}")}}
+	opts := renderfs.Options{Context: map[string]interface{}{"name": "demo"}}
+
+	inner := NewMemoryWriter()
+	if err := renderfs.Copy(source, Prefixed(writeOnlyWriter{inner: inner}, "app"), opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if got := string(inner.Contents()["app/a.txt"]); got != "demo" {
+		t.Fatalf("unexpected content %q", got)
+	}
+
+	// Skip and Fail need conflict detection, which the inner writer lacks,
+	// so they must fail rather than overwrite.
+	opts.Context["name"] = "changed"
+	for _, mode := range []renderfs.ConflictResolution{renderfs.Skip, renderfs.Fail} {
+		opts.OnConflict = mode
+		if err := renderfs.Copy(source, Prefixed(writeOnlyWriter{inner: inner}, "app"), opts); err == nil {
+			t.Fatalf("expected conflict detection error for mode %v", mode)
+		}
+		if got := string(inner.Contents()["app/a.txt"]); got != "demo" {
+			t.Fatalf("expected app/a.txt to be left alone for mode %v, got %q", mode, got)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package writers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/your-org/renderfs"
+)
+
+// Stage returns a copy of the writer's state that receives a transactional
+// copy's output. Commit replaces the writer's state with the stage, so writes
+// made directly to the writer in the meantime are lost.
+func (w *MemoryWriter) Stage() (renderfs.StagedWriter, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	stage := NewMemoryWriter()
+	for p, f := range w.files {
+		stage.files[p] = f
+	}
+	for p, mode := range w.dirs {
+		stage.dirs[p] = mode
+	}
+	for p, link := range w.symlinks {
+		stage.symlinks[p] = link
+	}
+	return &memoryStage{MemoryWriter: stage, parent: w}, nil
+}
+
+type memoryStage struct {
+	*MemoryWriter
+	parent *MemoryWriter
+}
+
+func (s *memoryStage) Commit() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.parent.mu.Lock()
+	defer s.parent.mu.Unlock()
+
+	s.parent.files = s.files
+	s.parent.dirs = s.dirs
+	s.parent.symlinks = s.symlinks
+	return nil
+}
+
+func (s *memoryStage) Rollback() error { return nil }
+
+// Stage creates a temporary directory next to DestDir that receives a
+// transactional copy's output. When DestDir does not exist yet, Commit
+// renames the whole directory into place atomically; otherwise it moves the
+// staged entries in one by one, so a failure part-way, such as a full disk,
+// can still leave some of them published. Rollback deletes the temporary
+// directory.
+func (w *OSWriter) Stage() (renderfs.StagedWriter, error) {
+	if err := w.mkdirParents(filepath.Dir(w.DestDir)); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(w.DestDir), "."+filepath.Base(w.DestDir)+".staging-")
+	if err != nil {
+		return nil, err
+	}
+	stage := *w
+	stage.DestDir = tmp
+	return &osStage{
+		OSWriter: &stage,
+		dest:     w,
+		dirs:     make(map[string]struct{}),
+		removed:  make(map[string]struct{}),
+	}, nil
+}
+
+// osStage writes into a temporary directory while answering Lstat and
+// ReadFile from the stage first and the destination second.
+type osStage struct {
+	*OSWriter
+	dest *OSWriter
+
+	mu      sync.Mutex
+	dirs    map[string]struct{} // created explicitly with MkdirAll
+	removed map[string]struct{} // removed from the destination on commit
+}
+
+func (s *osStage) MkdirAll(path string, perm fs.FileMode) error {
+	if err := s.OSWriter.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.dirs[normalizePath(path)] = struct{}{}
+	s.mu.Unlock()
+	return nil
+}
+
+// AppendFile seeds the staged file with the destination's content before
+// appending, so the committed file keeps what was there.
+func (s *osStage) AppendFile(path string, perm fs.FileMode) (io.WriteCloser, error) {
+	if _, err := s.OSWriter.Lstat(path); errors.Is(err, fs.ErrNotExist) && !s.isRemoved(path) {
+		data, err := s.dest.ReadFile(path)
+		switch {
+		case err == nil:
+			handle, err := s.OSWriter.CreateFile(path, perm)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := handle.Write(data); err != nil {
+				_ = handle.Close()
+				return nil, err
+			}
+			if err := handle.Close(); err != nil {
+				return nil, err
+			}
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+	}
+	return s.OSWriter.AppendFile(path, perm)
+}
+
+func (s *osStage) Lstat(path string) (fs.FileInfo, error) {
+	info, err := s.OSWriter.Lstat(path)
+	if !errors.Is(err, fs.ErrNotExist) || s.isRemoved(path) {
+		return info, err
+	}
+	return s.dest.Lstat(path)
+}
+
+func (s *osStage) ReadFile(path string) ([]byte, error) {
+	data, err := s.OSWriter.ReadFile(path)
+	if !errors.Is(err, fs.ErrNotExist) || s.isRemoved(path) {
+		return data, err
+	}
+	return s.dest.ReadFile(path)
+}
+
+// Remove deletes path from the stage, or schedules its removal from the
+// destination when it exists only there.
+func (s *osStage) Remove(path string) error {
+	err := s.OSWriter.Remove(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if _, err := s.dest.Lstat(path); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.removed[normalizePath(path)] = struct{}{}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *osStage) isRemoved(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.removed[normalizePath(path)]
+	return ok
+}
+
+func (s *osStage) Rollback() error {
+	return os.RemoveAll(s.DestDir)
+}
+
+func (s *osStage) Commit() error {
+	tmp := s.DestDir
+	defer os.RemoveAll(tmp)
+
+	if _, err := os.Lstat(s.dest.DestDir); errors.Is(err, fs.ErrNotExist) {
+		if err := s.dest.chmod(tmp, 0o755); err != nil {
+			return err
+		}
+		return os.Rename(tmp, s.dest.DestDir)
+	}
+
+	removed := make([]string, 0, len(s.removed))
+	for p := range s.removed {
+		removed = append(removed, p)
+	}
+	sort.Strings(removed)
+	for _, p := range removed {
+		if err := s.dest.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	// WalkDir visits parents before children, so directories exist before
+	// the entries moved into them.
+	return filepath.WalkDir(tmp, func(full string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(tmp, full)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := s.dest.join(filepath.ToSlash(rel))
+
+		if !d.IsDir() {
+			if err := os.Rename(full, target); err != nil {
+				return fmt.Errorf("move %s: %w", rel, err)
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		existing, err := os.Lstat(target)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err := os.Mkdir(target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return s.dest.chmod(target, info.Mode().Perm())
+		case err != nil:
+			return err
+		case !existing.IsDir():
+			return fmt.Errorf("move %s: destination is not a directory", rel)
+		}
+		// Existing directories keep their permissions unless the copy
+		// set them explicitly.
+		s.mu.Lock()
+		_, explicit := s.dirs[filepath.ToSlash(rel)]
+		s.mu.Unlock()
+		if explicit {
+			return s.dest.chmod(target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// Stage stages through the wrapped OSWriter and records the staged paths as
+// written once the stage is committed.
+func (w *GitWriter) Stage() (renderfs.StagedWriter, error) {
+	inner, err := w.OSWriter.Stage()
+	if err != nil {
+		return nil, err
+	}
+	return &gitStage{osStage: inner.(*osStage), git: w}, nil
+}
+
+type gitStage struct {
+	*osStage
+	git *GitWriter
+
+	mu      sync.Mutex
+	pending []string
+}
+
+func (s *gitStage) CreateFile(path string, perm fs.FileMode) (io.WriteCloser, error) {
+	handle, err := s.osStage.CreateFile(path, perm)
+	if err != nil {
+		return nil, err
+	}
+	s.note(path)
+	return handle, nil
+}
+
+func (s *gitStage) AppendFile(path string, perm fs.FileMode) (io.WriteCloser, error) {
+	handle, err := s.osStage.AppendFile(path, perm)
+	if err != nil {
+		return nil, err
+	}
+	s.note(path)
+	return handle, nil
+}
+
+func (s *gitStage) Symlink(oldname, newname string) error {
+	if err := s.osStage.Symlink(oldname, newname); err != nil {
+		return err
+	}
+	s.note(newname)
+	return nil
+}
+
+func (s *gitStage) note(p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, p)
+}
+
+func (s *gitStage) Commit() error {
+	if err := s.osStage.Commit(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.pending {
+		s.git.record(p)
+	}
+	return nil
+}
+
+var (
+	_ renderfs.Stager = (*MemoryWriter)(nil)
+	_ renderfs.Stager = (*OSWriter)(nil)
+	_ renderfs.Stager = (*GitWriter)(nil)
+)
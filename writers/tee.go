@@ -0,0 +1,120 @@
+package writers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/your-org/renderfs"
+)
+
+// Tee returns a Writer that duplicates every operation to both a and b. Reads
+// used for conflict detection (Lstat, ReadFile) are answered by a alone.
+func Tee(a, b renderfs.Writer) renderfs.Writer {
+	return &teeWriter{a: a, b: b}
+}
+
+type teeWriter struct {
+	a, b renderfs.Writer
+}
+
+// MkdirAll creates the directory in both writers.
+func (w *teeWriter) MkdirAll(p string, perm fs.FileMode) error {
+	if err := w.a.MkdirAll(p, perm); err != nil {
+		return err
+	}
+	return w.b.MkdirAll(p, perm)
+}
+
+// CreateFile opens the file in both writers and returns a handle that writes
+// to each of them.
+func (w *teeWriter) CreateFile(p string, perm fs.FileMode) (io.WriteCloser, error) {
+	first, err := w.a.CreateFile(p, perm)
+	if err != nil {
+		return nil, err
+	}
+	second, err := w.b.CreateFile(p, perm)
+	if err != nil {
+		_ = first.Close()
+		return nil, err
+	}
+	return &teeWriteCloser{
+		Writer: io.MultiWriter(first, second),
+		first:  first,
+		second: second,
+	}, nil
+}
+
+// AppendFile appends to the file in both writers when both support it.
+func (w *teeWriter) AppendFile(p string, perm fs.FileMode) (io.WriteCloser, error) {
+	aa, okA := w.a.(appendWriter)
+	ab, okB := w.b.(appendWriter)
+	if !okA || !okB {
+		return nil, fmt.Errorf("append %s: %w", p, errors.ErrUnsupported)
+	}
+	first, err := aa.AppendFile(p, perm)
+	if err != nil {
+		return nil, err
+	}
+	second, err := ab.AppendFile(p, perm)
+	if err != nil {
+		_ = first.Close()
+		return nil, err
+	}
+	return &teeWriteCloser{
+		Writer: io.MultiWriter(first, second),
+		first:  first,
+		second: second,
+	}, nil
+}
+
+// Symlink creates the link in both writers.
+func (w *teeWriter) Symlink(oldname, newname string) error {
+	if err := w.a.Symlink(oldname, newname); err != nil {
+		return err
+	}
+	return w.b.Symlink(oldname, newname)
+}
+
+// Lstat delegates to the first writer. When a cannot stat, it returns
+// errors.ErrUnsupported so Copy treats the tee as a destination without
+// conflict detection, as it would a itself.
+func (w *teeWriter) Lstat(p string) (fs.FileInfo, error) {
+	sw, ok := w.a.(lstatWriter)
+	if !ok {
+		return nil, fmt.Errorf("lstat %s: %w", p, errors.ErrUnsupported)
+	}
+	return sw.Lstat(p)
+}
+
+// ReadFile delegates to the first writer when it supports reading.
+func (w *teeWriter) ReadFile(p string) ([]byte, error) {
+	rw, ok := w.a.(readFileWriter)
+	if !ok {
+		return nil, fmt.Errorf("read %s: %w", p, errors.ErrUnsupported)
+	}
+	return rw.ReadFile(p)
+}
+
+// Remove deletes the path from both writers, which must both support it.
+func (w *teeWriter) Remove(p string) error {
+	ra, okA := w.a.(removeWriter)
+	rb, okB := w.b.(removeWriter)
+	if !okA || !okB {
+		return fmt.Errorf("remove %s: %w", p, errors.ErrUnsupported)
+	}
+	if err := ra.Remove(p); err != nil {
+		return err
+	}
+	return rb.Remove(p)
+}
+
+type teeWriteCloser struct {
+	io.Writer
+	first, second io.WriteCloser
+}
+
+func (wc *teeWriteCloser) Close() error {
+	return errors.Join(wc.first.Close(), wc.second.Close())
+}
@@ -0,0 +1,87 @@
+package writers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/your-org/renderfs"
+)
+
+func TestTeeWriterDuplicatesOutput(t *testing.T) {
+	dest := t.TempDir()
+	disk, err := NewOSWriter(dest)
+	if err != nil {
+		t.Fatalf("NewOSWriter: %v", err)
+	}
+	memory := NewMemoryWriter()
+	writer := Tee(disk, memory)
+
+	if err := writer.MkdirAll("conf", 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	handle, err := writer.CreateFile("conf/app.ini", 0o600)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	if _, err := handle.Write([]byte("debug = true\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := handle.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := writer.Symlink("conf/app.ini", "app.ini"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	onDisk, err := os.ReadFile(filepath.Join(dest, "conf/app.ini"))
+	if err != nil {
+		t.Fatalf("read disk file: %v", err)
+	}
+	inMemory := memory.Contents()["conf/app.ini"]
+	if string(onDisk) != "debug = true\n" || string(onDisk) != string(inMemory) {
+		t.Fatalf("expected identical content, disk=%q memory=%q", onDisk, inMemory)
+	}
+	if mode, ok := memory.FileMode("conf/app.ini"); !ok || mode != 0o600 {
+		t.Fatalf("expected memory mode 600, got %v (ok=%v)", mode, ok)
+	}
+	if _, err := os.Readlink(filepath.Join(dest, "app.ini")); err != nil {
+		t.Fatalf("expected symlink on disk: %v", err)
+	}
+	if _, err := memory.Lstat("app.ini"); err != nil {
+		t.Fatalf("expected symlink in memory: %v", err)
+	}
+}
+
+func TestTeeWriterWithoutLstat(t *testing.T) {
+	source := fstest.MapFS{"a.txt": {Data: []byte("{{ name }}")}}
+	opts := renderfs.Options{Context: map[string]interface{}{"name": "demo"}}
+
+	first, second := NewMemoryWriter(), NewMemoryWriter()
+	if err := renderfs.Copy(source, Tee(writeOnlyWriter{inner: first}, second), opts); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	for _, w := range []*MemoryWriter{first, second} {
+		if got := string(w.Contents()["a.txt"]); got != "demo" {
+			t.Fatalf("unexpected content %q", got)
+		}
+	}
+
+	// Fail and OnceGlobs need conflict detection, which a lacks, so they
+	// must fail rather than overwrite either writer.
+	opts.Context["name"] = "changed"
+	for name, changed := range map[string]renderfs.Options{
+		"fail": {Context: opts.Context, OnConflict: renderfs.Fail},
+		"once": {Context: opts.Context, OnceGlobs: []string{"a.txt"}},
+	} {
+		if err := renderfs.Copy(source, Tee(writeOnlyWriter{inner: first}, second), changed); err == nil {
+			t.Fatalf("%s: expected conflict detection error", name)
+		}
+		for _, w := range []*MemoryWriter{first, second} {
+			if got := string(w.Contents()["a.txt"]); got != "demo" {
+				t.Fatalf("%s: expected a.txt to be left alone, got %q", name, got)
+			}
+		}
+	}
+}